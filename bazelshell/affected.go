@@ -0,0 +1,290 @@
+package bazel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+
+	"github.com/open-ch/go-libs/bazelshell/buildfile"
+	"github.com/open-ch/go-libs/bazelshell/query"
+	"github.com/open-ch/go-libs/gitshell"
+)
+
+// DefaultUniverse is the target pattern reverse dependencies are searched in by default
+const DefaultUniverse = "//..."
+
+// AffectedOptions configures the computation of affected targets
+type AffectedOptions struct {
+	// Universe is the target pattern the reverse dependencies are searched in, defaults to DefaultUniverse
+	Universe string
+	// Flags are passed to every query
+	Flags []string
+}
+
+// Affected lists the targets affected by the changes between two commits
+type Affected struct {
+	// ChangedFiles are the changed files within the workspace, relative to it
+	ChangedFiles []string
+	// All is true when files configuring the external repositories changed (MODULE.bazel, WORKSPACE,
+	// the lockfile or .bzl files loaded by them): every target of the universe is affected
+	All bool
+	// Sources are what the changes were mapped to: source file labels and //pkg:all patterns
+	// for packages with BUILD file changes, deleted files or changed .bzl files loaded by them.
+	// It is the universe when All is set.
+	Sources []string
+	// Targets are the targets of the universe depending on the sources, the sources included
+	Targets []string
+	// Tests are the test targets among Targets, test suites expanded
+	Tests []string
+}
+
+// AffectedTargets computes the targets affected by the changes between two commits in the workspace folder,
+// see Client.AffectedTargets
+func AffectedTargets(folder, previousCommit, currentCommit string, opts AffectedOptions) (*Affected, error) {
+	return NewClient(folder).AffectedTargets(previousCommit, currentCommit, opts)
+}
+
+// AffectedTargets computes the targets affected by the changes between two commits.
+// The workspace is expected to be checked out at currentCommit, it may be a subdirectory of the git repository.
+// Changed files are mapped to their source file labels, deleted files and BUILD file changes to all the
+// targets of their package, and .bzl changes to all the packages loading them (rbuildfiles).
+// Changes to MODULE.bazel, its includes and lockfile, WORKSPACE or the .bzl files they load affect the whole universe.
+// Errors in the queries (i.e. broken packages) are tolerated with --keep_going.
+func (c *Client) AffectedTargets(previousCommit, currentCommit string, opts AffectedOptions) (*Affected, error) {
+	workspace, err := filepath.Abs(c.WorkspaceDir)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving workspace %s: %w", c.WorkspaceDir, err)
+	}
+	gitRoot, err := gitshell.GitResolveRoot(workspace)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving git root of %s: %w", workspace, err)
+	}
+	prefix, err := workspacePrefix(gitRoot, workspace)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := gitshell.GitFileDiff(gitRoot, previousCommit, currentCommit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing changes between %s and %s: %w", previousCommit, currentCommit, err)
+	}
+
+	affected := &Affected{}
+	var files, bzlFiles []string
+	packages := map[string]bool{}
+	for changedPath, change := range changes {
+		if prefix != "" && !strings.HasPrefix(changedPath, prefix+"/") {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(changedPath, prefix), "/")
+		affected.ChangedFiles = append(affected.ChangedFiles, rel)
+		base := path.Base(rel)
+		switch {
+		case isWorkspaceFile(rel):
+			affected.All = true
+		case base == "BUILD" || base == "BUILD.bazel":
+			// A deleted BUILD file moves its files to the parent package
+			if pkg, ok := FindPackage(workspace, path.Dir(rel)); ok {
+				packages[pkg] = true
+			}
+		case strings.HasSuffix(base, ".bzl"):
+			bzlFiles = append(bzlFiles, rel)
+		case change == gitshell.Deleted:
+			// The file has no label anymore, the targets referring to it are in its package
+			if pkg, ok := FindPackage(workspace, path.Dir(rel)); ok {
+				packages[pkg] = true
+			}
+		default:
+			files = append(files, rel)
+		}
+	}
+	sort.Strings(affected.ChangedFiles)
+	sort.Strings(files)
+	sort.Strings(bzlFiles)
+
+	universe := opts.Universe
+	if universe == "" {
+		universe = DefaultUniverse
+	}
+	flags := append([]string{"--keep_going"}, opts.Flags...)
+
+	if !affected.All && len(bzlFiles) > 0 {
+		workspaceBzl, err := workspaceBzlFiles(workspace)
+		if err != nil {
+			return nil, err
+		}
+		for _, bzlFile := range bzlFiles {
+			affected.All = affected.All || workspaceBzl[bzlFile]
+		}
+	}
+	if affected.All {
+		affected.Sources = []string{universe}
+		if affected.Targets, err = c.queryLabels(query.Raw(universe), flags); err != nil {
+			return nil, err
+		}
+		if affected.Tests, err = c.queryLabels(query.Tests(query.Raw(universe)), flags); err != nil {
+			return nil, err
+		}
+		return affected, nil
+	}
+
+	if len(files) > 0 {
+		// Files outside of any package are reported as errors, --keep_going returns the others
+		set, err := query.Set(files...)
+		if err != nil {
+			return nil, err
+		}
+		labels, err := c.queryLabels(set, flags)
+		if err != nil {
+			return nil, err
+		}
+		affected.Sources = append(affected.Sources, labels...)
+	}
+	if len(bzlFiles) > 0 {
+		// rbuildfiles takes path fragments and returns the BUILD files loading them, transitively
+		rbuildfiles, err := query.RBuildFiles(bzlFiles...)
+		if err != nil {
+			return nil, err
+		}
+		buildFiles, err := c.queryLabels(rbuildfiles,
+			append([]string{"--universe_scope=" + universe, "--order_output=no"}, flags...))
+		if err != nil {
+			return nil, err
+		}
+		for _, buildFile := range buildFiles {
+			label, err := ParseLabel(buildFile)
+			if err != nil {
+				return nil, err
+			}
+			packages[label.Package] = true
+		}
+	}
+	for pkg := range packages {
+		affected.Sources = append(affected.Sources, "//"+pkg+":all")
+	}
+	if len(affected.Sources) == 0 {
+		return affected, nil
+	}
+	sort.Strings(affected.Sources)
+
+	// The universe may be any query expression, i.e. //... except //third_party/...
+	sources, err := query.Set(affected.Sources...)
+	if err != nil {
+		return nil, err
+	}
+	rdeps := query.RDeps(query.Raw(universe), sources)
+	if affected.Targets, err = c.queryLabels(rdeps, flags); err != nil {
+		return nil, err
+	}
+	if affected.Tests, err = c.queryLabels(query.Tests(rdeps), flags); err != nil {
+		return nil, err
+	}
+	return affected, nil
+}
+
+// isWorkspaceFile returns true for the files configuring the external repositories of a workspace
+func isWorkspaceFile(rel string) bool {
+	switch rel {
+	case "MODULE.bazel", "MODULE.bazel.lock", "WORKSPACE", "WORKSPACE.bazel", "WORKSPACE.bzlmod":
+		return true
+	}
+	// Included from MODULE.bazel with include()
+	return strings.HasSuffix(rel, ".MODULE.bazel")
+}
+
+// workspaceBzlFiles returns the .bzl files of the main repository loaded by MODULE.bazel (use_extension,
+// use_repo_rule, include) and WORKSPACE files, transitively, by path relative to the workspace
+func workspaceBzlFiles(workspace string) (map[string]bool, error) {
+	bzlFiles := map[string]bool{}
+	// The root module may refer to itself by its name
+	mainRepos := map[string]bool{"": true}
+	var pending []Label
+	for _, name := range []string{"MODULE.bazel", "WORKSPACE", "WORKSPACE.bazel", "WORKSPACE.bzlmod"} {
+		pending = append(pending, Label{Name: name})
+	}
+	visited := map[string]bool{}
+	for len(pending) > 0 {
+		file := pending[0]
+		pending = pending[1:]
+		rel := path.Join(file.Package, file.Name)
+		if visited[rel] {
+			continue
+		}
+		visited[rel] = true
+		f, err := buildfile.ParseFile(filepath.Join(workspace, filepath.FromSlash(rel)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(rel, ".bzl") {
+			bzlFiles[rel] = true
+		}
+
+		var refs []string
+		build.Walk(f, func(x build.Expr, _ []build.Expr) {
+			switch x := x.(type) {
+			case *build.LoadStmt:
+				refs = append(refs, x.Module.Value)
+			case *build.CallExpr:
+				if f.Type != build.TypeModule || len(x.List) == 0 {
+					return
+				}
+				switch rule := f.Rule(x); rule.Kind() {
+				case "module":
+					mainRepos[rule.Name()] = true
+					mainRepos[rule.AttrString("repo_name")] = true
+				case "use_extension", "use_repo_rule", "include":
+					refs = append(refs, stringValue(x.List[0]))
+				}
+			}
+		})
+		for _, ref := range refs {
+			label, err := ParseRelativeLabel(ref, Label{Package: file.Package})
+			if err != nil || !mainRepos[label.RepoName()] {
+				// Invalid labels are reported by Bazel, files of external repositories are not in the diff
+				continue
+			}
+			pending = append(pending, Label{Package: label.Package, Name: label.Name})
+		}
+	}
+	return bzlFiles, nil
+}
+
+// workspacePrefix returns the path of the workspace relative to the git root, empty if they are the same
+func workspacePrefix(gitRoot, workspace string) (string, error) {
+	// The git root is reported with symlinks resolved
+	resolved, err := filepath.EvalSymlinks(workspace)
+	if err != nil {
+		return "", fmt.Errorf("error resolving workspace %s: %w", workspace, err)
+	}
+	rel, err := filepath.Rel(gitRoot, resolved)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("workspace %s is not within git repository %s", workspace, gitRoot)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// queryLabels runs a query with --output=label and returns the sorted labels, partial results included
+func (c *Client) queryLabels(expr query.Expr, flags []string) ([]string, error) {
+	output, err := c.runQuery("query", expr.String(), append([]string{"--output=label"}, flags...))
+	if err != nil {
+		return nil, err
+	}
+	labels, err := ParseLabelOutput(bytes.NewReader(output))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(labels)
+	return labels, nil
+}
@@ -0,0 +1,156 @@
+package bazel
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/bazelshell/bazeltest"
+	"github.com/open-ch/go-libs/gitshell/gitshelltest"
+)
+
+// fakeQueryBazel answers queries by their function and logs the query expressions, one per line
+const fakeQueryBazel = `for arg in "$@"; do query="$arg"; done
+echo "$query" >> "$QUERY_LOG"
+case "$query" in
+  set*) echo //lib:lib.go; echo "ERROR: no such target 'notes.txt'" >&2; exit 3 ;;
+  rbuildfiles*) echo //app:BUILD.bazel ;;
+  tests*) echo //app:app_test ;;
+  rdeps*) echo //app:app; echo //app:app_test; echo //lib:lib ;;
+esac`
+
+func TestAffectedTargets(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{Steps: []gitshelltest.Step{
+		gitshelltest.Commit{Message: "initial", Files: map[string]string{
+			"README.md":               "outside of the workspace",
+			"ws/MODULE.bazel":         "",
+			"ws/lib/BUILD.bazel":      "go_library(name = 'lib')",
+			"ws/lib/lib.go":           "package lib",
+			"ws/lib/old.go":           "package lib",
+			"ws/lib/internal/util.go": "package internal",
+			"ws/app/BUILD.bazel":      "load('//rules:defs.bzl', 'app')",
+			"ws/docs/BUILD":           "",
+			"ws/rules/BUILD":          "",
+			"ws/rules/defs.bzl":       "def app(): pass",
+		}},
+		gitshelltest.Commit{
+			Message: "change",
+			Files: map[string]string{
+				"README.md":         "changed outside of the workspace",
+				"ws/lib/lib.go":     "package lib // changed",
+				"ws/notes.txt":      "added outside of any package",
+				"ws/docs/BUILD":     "filegroup(name = 'docs')",
+				"ws/rules/defs.bzl": "def app(): return",
+			},
+			Delete: []string{"ws/lib/internal/util.go", "ws/lib/old.go"},
+		},
+	}})
+
+	queryLog := filepath.Join(t.TempDir(), "queries")
+	client := NewClient(filepath.Join(repo.Path, "ws"))
+	client.Binary = writeScript(t, fakeQueryBazel)
+	client.Env = []string{"QUERY_LOG=" + queryLog}
+
+	affected, err := client.AffectedTargets("HEAD~1", "HEAD", AffectedOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs/BUILD", "lib/internal/util.go", "lib/lib.go", "lib/old.go", "notes.txt", "rules/defs.bzl"}, affected.ChangedFiles)
+	assert.Equal(t, []string{"//app:all", "//docs:all", "//lib:all", "//lib:lib.go"}, affected.Sources)
+	assert.Equal(t, []string{"//app:app", "//app:app_test", "//lib:lib"}, affected.Targets)
+	assert.Equal(t, []string{"//app:app_test"}, affected.Tests)
+
+	queries, err := os.ReadFile(queryLog)
+	require.NoError(t, err)
+	rdeps := "rdeps(//..., set(//app:all //docs:all //lib:all //lib:lib.go))"
+	assert.Equal(t, []string{
+		"set(lib/lib.go notes.txt)",
+		"rbuildfiles(rules/defs.bzl)",
+		rdeps,
+		"tests(" + rdeps + ")",
+	}, strings.Split(strings.TrimSpace(string(queries)), "\n"))
+}
+
+func TestAffectedTargetsOutsideWorkspace(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{Steps: []gitshelltest.Step{
+		gitshelltest.Commit{Message: "initial", Files: map[string]string{"ws/MODULE.bazel": ""}},
+		gitshelltest.Commit{Message: "outside", Files: map[string]string{"other/BUILD": "", "ws.txt": ""}},
+	}})
+	client := NewClient(filepath.Join(repo.Path, "ws"))
+	client.Binary = writeScript(t, "echo unexpected call >&2; exit 2")
+
+	affected, err := client.AffectedTargets("HEAD~1", "HEAD", AffectedOptions{Universe: "//src/..."})
+	require.NoError(t, err)
+	assert.Empty(t, affected.ChangedFiles)
+	assert.Empty(t, affected.Targets)
+}
+
+func TestAffectedTargetsWorkspaceChanges(t *testing.T) {
+	initial := map[string]string{
+		"MODULE.bazel": `module(name = "example")
+
+go_deps = use_extension("//rules:extensions.bzl", "go_deps")
+include("//third_party:deps.MODULE.bazel")
+`,
+		"third_party/BUILD":             "",
+		"third_party/deps.MODULE.bazel": `tool = use_repo_rule("@example//third_party:tool.bzl", "tool")`,
+		"third_party/tool.bzl":          "def tool(): pass",
+		"rules/BUILD":                   "",
+		"rules/extensions.bzl":          `load(":versions.bzl", "VERSIONS")`,
+		"rules/versions.bzl":            "VERSIONS = []",
+		"rules/defs.bzl":                "def app(): pass",
+		"WORKSPACE.bzlmod":              `load("//legacy:repos.bzl", "repos")`,
+		"legacy/BUILD":                  "",
+		"legacy/repos.bzl":              "def repos(): pass",
+		"app/BUILD":                     "",
+		"app/main.go":                   "package main",
+	}
+
+	var tests = []struct {
+		name     string
+		changed  string
+		expected bool
+	}{
+		{name: "module file", changed: "MODULE.bazel", expected: true},
+		{name: "lockfile", changed: "MODULE.bazel.lock", expected: true},
+		{name: "workspace file", changed: "WORKSPACE", expected: true},
+		{name: "included module file", changed: "third_party/deps.MODULE.bazel", expected: true},
+		{name: "bzl file of a module extension", changed: "rules/extensions.bzl", expected: true},
+		{name: "bzl file loaded by an extension", changed: "rules/versions.bzl", expected: true},
+		{name: "bzl file of a repository rule", changed: "third_party/tool.bzl", expected: true},
+		{name: "bzl file loaded by the workspace", changed: "legacy/repos.bzl", expected: true},
+		{name: "other bzl file", changed: "rules/defs.bzl", expected: false},
+		{name: "source file", changed: "app/main.go", expected: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := gitshelltest.NewRepo(t, gitshelltest.Spec{Steps: []gitshelltest.Step{
+				gitshelltest.Commit{Message: "initial", Files: initial},
+				gitshelltest.Commit{Message: "change", Files: map[string]string{tc.changed: "# changed"}},
+			}})
+			fake := bazeltest.New(t,
+				bazeltest.Rule{Pattern: "--keep_going tests\\(", Stdout: "//app:app_test\n"},
+				bazeltest.Rule{Pattern: "--keep_going //...$", Stdout: "//app:app\n//app:app_test\n"},
+				bazeltest.Rule{Pattern: "--keep_going rdeps\\(", Stdout: "//app:app\n"},
+				bazeltest.Rule{Pattern: "--keep_going (set|rbuildfiles)\\("},
+			)
+			client := NewClient(repo.Path)
+			client.Binary = fake.Path
+
+			affected, err := client.AffectedTargets("HEAD~1", "HEAD", AffectedOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, []string{tc.changed}, affected.ChangedFiles)
+			assert.Equal(t, tc.expected, affected.All)
+			if tc.expected {
+				assert.Equal(t, []string{"//..."}, affected.Sources)
+				assert.Equal(t, []string{"//app:app", "//app:app_test"}, affected.Targets)
+				assert.Equal(t, []string{"//app:app_test"}, affected.Tests)
+				fake.AssertInvoked("rdeps\\(", 0)
+			} else {
+				fake.AssertInvoked("--keep_going //...$", 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Package bazeltest installs a fake bazel executable replaying scripted outputs, to test code
+// driving Bazel without Bazel. The fake is a shell script: it is not supported on windows.
+package bazeltest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Rule answers the invocations whose arguments match Pattern, the first matching rule is used.
+// Invocations matching no rule print an error and exit with code 2, like unknown commands.
+type Rule struct {
+	// Pattern is an extended regular expression matched against the arguments joined by spaces,
+	// startup options and command included, i.e. "^query .*//lib/...". An empty pattern matches everything.
+	// It is matched with grep -E by the fake and with Go's regexp by Fake.Matching, see CheckPattern
+	// for the patterns both agree on.
+	Pattern  string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// BuildEvents are JSON Build Event Protocol events, one per line, written to the file given
+	// with --build_event_json_file
+	BuildEvents string
+}
+
+// Invocation is a recorded run of the fake
+type Invocation struct {
+	// Dir is the working directory
+	Dir  string
+	Args []string
+}
+
+// String returns the arguments joined by spaces as matched by the patterns, newlines replaced by spaces
+// so that the patterns are matched against a single line
+func (i Invocation) String() string {
+	return strings.ReplaceAll(strings.Join(i.Args, " "), "\n", " ")
+}
+
+// interval matches the braces of a repetition: {n}, {n,} or {n,m}
+var interval = regexp.MustCompile(`^\{[0-9]+(,[0-9]*)?\}`)
+
+// CheckPattern fails for patterns grep -E and Go's regexp may disagree on. Patterns have to be
+// POSIX extended regular expressions without escapes of letters or digits (i.e. \d, \b or \n),
+// backslashes in bracket expressions or braces other than repetitions.
+func CheckPattern(pattern string) error {
+	if _, err := regexp.CompilePOSIX(pattern); err != nil {
+		return err
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i+1 < len(pattern) && isAlphanumeric(pattern[i+1]) {
+				return fmt.Errorf("escape sequence \\%c in %q is not portable", pattern[i+1], pattern)
+			}
+			i++
+		case '[':
+			end := bracketEnd(pattern, i)
+			if strings.Contains(pattern[i:end], "\\") {
+				return fmt.Errorf("backslash in bracket expression %s of %q is not portable", pattern[i:end+1], pattern)
+			}
+			i = end
+		case '{':
+			if !interval.MatchString(pattern[i:]) {
+				return fmt.Errorf("brace in %q is not a repetition, escape it as \\{", pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// bracketEnd returns the index of the ] closing the bracket expression starting at start,
+// the pattern being valid. A ] right after the opening [ or [^ is part of the expression.
+func bracketEnd(pattern string, start int) int {
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+	for ; i < len(pattern); i++ {
+		if pattern[i] == ']' {
+			return i
+		}
+		// Character classes: [:alpha:]
+		if pattern[i] == '[' && i+1 < len(pattern) && pattern[i+1] == ':' {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				i += end + 3
+			}
+		}
+	}
+	return len(pattern) - 1
+}
+
+func isAlphanumeric(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// Fake is a fake bazel executable created by New
+type Fake struct {
+	// Path is the executable, to be used as the binary of a client
+	Path string
+	dir  string
+	t    testing.TB
+}
+
+// New writes a fake bazel executable answering with the given rules to a temporary directory,
+// failing the test on any error. The directory is removed when the test ends.
+func New(t testing.TB, rules ...Rule) *Fake {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("bazeltest: the fake bazel is a shell script, not supported on windows")
+	}
+	f := &Fake{dir: t.TempDir(), t: t}
+	f.Path = filepath.Join(f.dir, "bin", "bazel")
+	for i, rule := range rules {
+		if err := CheckPattern(rule.Pattern); err != nil {
+			t.Fatalf("bazeltest: invalid pattern of rule %d: %v", i, err)
+		}
+		ruleDir := filepath.Join(f.dir, "rules", fmt.Sprintf("%04d", i))
+		files := map[string]string{
+			"pattern":   rule.Pattern,
+			"stdout":    rule.Stdout,
+			"stderr":    rule.Stderr,
+			"exit_code": strconv.Itoa(rule.ExitCode),
+		}
+		if rule.BuildEvents != "" {
+			files["events"] = rule.BuildEvents
+		}
+		for name, content := range files {
+			f.writeFile(filepath.Join(ruleDir, name), content, 0644)
+		}
+	}
+	f.writeFile(f.Path, fmt.Sprintf(script, shellQuote(f.dir)), 0755)
+	return f
+}
+
+// script is the fake executable, %s is the quoted directory of the fake.
+// Invocations are logged as the working directory and the arguments, each followed by a unit separator (\037),
+// and terminated by a record separator (\036) so that arguments may contain newlines.
+// Patterns are matched against a single line, newlines replaced by spaces like in Invocation.String.
+const script = `#!/bin/sh
+dir=%s
+{ printf '%%s\037' "$PWD" "$@"; printf '\036'; } >> "$dir/invocations"
+events=
+previous=
+for arg in "$@"; do
+  case "$previous" in --build_event_json_file) events="$arg" ;; esac
+  case "$arg" in --build_event_json_file=*) events="${arg#--build_event_json_file=}" ;; esac
+  previous="$arg"
+done
+for rule in "$dir"/rules/*; do
+  [ -d "$rule" ] || continue
+  if { printf '%%s' "$*" | tr '\n' ' '; echo; } | grep -Eq -e "$(cat "$rule/pattern")"; then
+    if [ -n "$events" ] && [ -f "$rule/events" ]; then
+      cp "$rule/events" "$events"
+    fi
+    cat "$rule/stdout"
+    cat "$rule/stderr" >&2
+    exit "$(cat "$rule/exit_code")"
+  fi
+done
+echo "bazeltest: no rule matching: $*" >&2
+exit 2
+`
+
+// shellQuote quotes a string for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (f *Fake) writeFile(path, content string, perm os.FileMode) {
+	f.t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		f.t.Fatalf("bazeltest: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		f.t.Fatalf("bazeltest: %v", err)
+	}
+}
+
+// InstallOnPath puts the fake first on the PATH for the duration of the test,
+// for code running bazel without a configurable binary. Tests using it cannot run in parallel.
+func (f *Fake) InstallOnPath() {
+	f.t.Helper()
+	f.t.Setenv("PATH", filepath.Dir(f.Path)+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// Invocations returns the recorded invocations, in order
+func (f *Fake) Invocations() []Invocation {
+	f.t.Helper()
+	data, err := os.ReadFile(filepath.Join(f.dir, "invocations"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		f.t.Fatalf("bazeltest: %v", err)
+	}
+	var invocations []Invocation
+	for _, record := range strings.Split(strings.TrimSuffix(string(data), "\036"), "\036") {
+		fields := strings.Split(strings.TrimSuffix(record, "\037"), "\037")
+		invocations = append(invocations, Invocation{Dir: fields[0], Args: fields[1:]})
+	}
+	return invocations
+}
+
+// Matching returns the recorded invocations whose arguments match the regular expression, see Rule.Pattern
+func (f *Fake) Matching(pattern string) []Invocation {
+	f.t.Helper()
+	if err := CheckPattern(pattern); err != nil {
+		f.t.Fatalf("bazeltest: invalid pattern: %v", err)
+	}
+	re := regexp.MustCompilePOSIX(pattern)
+	var matching []Invocation
+	for _, invocation := range f.Invocations() {
+		if re.MatchString(invocation.String()) {
+			matching = append(matching, invocation)
+		}
+	}
+	return matching
+}
+
+// AssertInvoked fails the test unless the fake was invoked the given number of times
+// with arguments matching the regular expression
+func (f *Fake) AssertInvoked(pattern string, times int) bool {
+	f.t.Helper()
+	matching := f.Matching(pattern)
+	if len(matching) == times {
+		return true
+	}
+	var all []string
+	for _, invocation := range f.Invocations() {
+		all = append(all, "\n\t"+invocation.String())
+	}
+	f.t.Errorf("bazeltest: expected %d invocations matching %q, got %d among:%s", times, pattern, len(matching), strings.Join(all, ""))
+	return false
+}
@@ -0,0 +1,142 @@
+package bazeltest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	fake := New(t,
+		Rule{Pattern: "^query .*//lib", Stdout: "//lib:lib\n"},
+		Rule{Pattern: "^build ", Stderr: "ERROR: build failed\n", ExitCode: 1},
+		Rule{Pattern: "^query ", Stdout: "//:all\n"},
+	)
+	dir := t.TempDir()
+
+	run := func(args ...string) (string, string, int) {
+		cmd := exec.Command(fake.Path, args...)
+		cmd.Dir = dir
+		var stdout, stderr strings.Builder
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		_ = cmd.Run()
+		return stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()
+	}
+
+	stdout, _, code := run("query", "deps(//lib/...)")
+	assert.Equal(t, "//lib:lib\n", stdout)
+	assert.Equal(t, 0, code)
+
+	stdout, _, code = run("query", "//...")
+	assert.Equal(t, "//:all\n", stdout)
+	assert.Equal(t, 0, code)
+
+	_, stderr, code := run("build", "//...")
+	assert.Equal(t, "ERROR: build failed\n", stderr)
+	assert.Equal(t, 1, code)
+
+	_, stderr, code = run("version")
+	assert.Equal(t, "bazeltest: no rule matching: version\n", stderr)
+	assert.Equal(t, 2, code)
+
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	invocations := fake.Invocations()
+	require.Len(t, invocations, 4)
+	assert.Equal(t, []string{"query", "deps(//lib/...)"}, invocations[0].Args)
+	assert.Contains(t, []string{dir, resolvedDir}, invocations[0].Dir)
+	fake.AssertInvoked("^query ", 2)
+	fake.AssertInvoked("^build //\\.\\.\\.$", 1)
+	assert.Empty(t, fake.Matching("^test "))
+}
+
+func TestFakeArgsWithSpecialCharacters(t *testing.T) {
+	fake := New(t, Rule{})
+	query := "let v = deps(//a) in\n$v - \"//b:c d\""
+	require.NoError(t, exec.Command(fake.Path, "query", query, "").Run())
+
+	invocations := fake.Invocations()
+	require.Len(t, invocations, 1)
+	assert.Equal(t, []string{"query", query, ""}, invocations[0].Args)
+}
+
+func TestFakeBuildEvents(t *testing.T) {
+	events := `{"id":{"started":{}},"started":{"uuid":"1"}}` + "\n"
+	fake := New(t, Rule{Pattern: "^build ", BuildEvents: events})
+
+	for _, flag := range [][]string{{"--build_event_json_file=%s"}, {"--build_event_json_file", "%s"}} {
+		out := filepath.Join(t.TempDir(), "events.json")
+		args := []string{"build"}
+		for _, arg := range flag {
+			args = append(args, strings.Replace(arg, "%s", out, 1))
+		}
+		require.NoError(t, exec.Command(fake.Path, append(args, "//...")...).Run())
+		written, err := os.ReadFile(out)
+		require.NoError(t, err)
+		assert.Equal(t, events, string(written))
+	}
+}
+
+func TestFakeInstallOnPath(t *testing.T) {
+	fake := New(t, Rule{Stdout: "bazel 7.1.0\n"})
+	fake.InstallOnPath()
+
+	output, err := exec.Command("bazel", "version").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "bazel 7.1.0\n", string(output))
+	fake.AssertInvoked("^version$", 1)
+}
+
+func TestFakeArgsWithNewlines(t *testing.T) {
+	fake := New(t,
+		Rule{Pattern: "^query let v = //a in \\$v$", Stdout: "matched\n"},
+		Rule{Pattern: "^build ", Stdout: "build\n"},
+	)
+
+	output, err := exec.Command(fake.Path, "query", "let v = //a in\n$v").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "matched\n", string(output))
+
+	output, err = exec.Command(fake.Path, "query", "//a\nbuild //b").Output()
+	assert.Error(t, err, "Expected anchors not to match within arguments")
+	assert.Empty(t, string(output))
+	fake.AssertInvoked("^query let v = //a in \\$v$", 1)
+	assert.Empty(t, fake.Matching("^build "))
+}
+
+func TestCheckPattern(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		valid   bool
+	}{
+		{pattern: "", valid: true},
+		{pattern: "^query --output=label (deps|rdeps)\\(//lib/\\.\\.\\.\\)$", valid: true},
+		{pattern: "^build [[:alnum:]_]+ x{2,3} []a]", valid: true},
+		{pattern: "^query \\d", valid: false},
+		{pattern: "\\bbuild\\b", valid: false},
+		{pattern: "a\\nb", valid: false},
+		{pattern: "(?i)build", valid: false},
+		{pattern: "build (?:x)", valid: false},
+		{pattern: "[\\.]", valid: false},
+		{pattern: "x{,3}", valid: false},
+		{pattern: "{", valid: false},
+		{pattern: "(", valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern, func(t *testing.T) {
+			err := CheckPattern(tc.pattern)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,585 @@
+package bazel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TestStatus is the outcome of a test run or of all the runs of a test target
+type TestStatus string
+
+// Test statuses as defined by the BEP TestStatus enum
+const (
+	TestNoStatus                TestStatus = "NO_STATUS"
+	TestPassed                  TestStatus = "PASSED"
+	TestFlaky                   TestStatus = "FLAKY"
+	TestTimeout                 TestStatus = "TIMEOUT"
+	TestFailed                  TestStatus = "FAILED"
+	TestIncomplete              TestStatus = "INCOMPLETE"
+	TestRemoteFailure           TestStatus = "REMOTE_FAILURE"
+	TestFailedToBuild           TestStatus = "FAILED_TO_BUILD"
+	TestToolHaltedBeforeTesting TestStatus = "TOOL_HALTED_BEFORE_TESTING"
+)
+
+// BuildEvent is a single event of the Build Event Protocol, as written with --build_event_json_file.
+// Only the payload matching the Kind is set, other events are available as Raw JSON.
+// see https://bazel.build/remote/bep for more details
+type BuildEvent struct {
+	// Kind is the name of the event id, i.e. targetCompleted or testResult
+	Kind string
+	// Label is the target the event is about, if any
+	Label string
+	// Aspect is the aspect a targetCompleted event is about, empty for the target itself
+	Aspect string
+	// LastMessage is true for the last event of the stream
+	LastMessage bool
+	// Raw is the whole event as written by Bazel
+	Raw json.RawMessage
+
+	TargetCompleted *TargetCompleted
+	TestResult      *TestResult
+	TestSummary     *TestSummary
+	ActionCompleted *ActionCompleted
+	NamedSetOfFiles *NamedSetOfFiles
+	BuildFinished   *BuildFinished
+	BuildMetrics    *BuildMetrics
+	// Aborted is set when Bazel gave up on the event, the Kind is the one of the aborted event
+	Aborted *Aborted
+}
+
+// File is a file reported in build events
+type File struct {
+	Name string `json:"name"`
+	// URI is the location of the file, i.e. file:///path or bytestream://cache/blobs/...
+	URI        string   `json:"uri"`
+	PathPrefix []string `json:"pathPrefix"`
+}
+
+// OutputGroup is a named group of outputs of a target, the files are in named sets
+type OutputGroup struct {
+	Name     string `json:"name"`
+	FileSets []struct {
+		ID string `json:"id"`
+	} `json:"fileSets"`
+}
+
+// TargetCompleted reports that a target was built, or failed to
+type TargetCompleted struct {
+	Success        bool
+	OutputGroups   []OutputGroup
+	FailureMessage string
+}
+
+// TestResult reports a single attempt of a single shard of a test run
+type TestResult struct {
+	Run           int
+	Shard         int
+	Attempt       int
+	Status        TestStatus
+	CachedLocally bool
+	// CachedRemotely is true if the result was taken from the remote cache
+	CachedRemotely bool
+	Strategy       string
+	Duration       time.Duration
+	// Outputs are the files produced by the test, i.e. test.log and test.xml
+	Outputs []File
+}
+
+// TestSummary aggregates all runs, shards and attempts of a test target
+type TestSummary struct {
+	OverallStatus  TestStatus
+	TotalRunCount  int
+	ShardCount     int
+	AttemptCount   int
+	TotalNumCached int
+	Passed         []File
+	Failed         []File
+	Duration       time.Duration
+}
+
+// ActionCompleted reports an action that failed, successful actions are only reported
+// with --build_event_publish_all_actions
+type ActionCompleted struct {
+	Success       bool
+	Mnemonic      string
+	ExitCode      int
+	PrimaryOutput string
+	Stdout        *File
+	Stderr        *File
+	CommandLine   []string
+	// FailureMessage describes why the action failed
+	FailureMessage string
+}
+
+// NamedSetOfFiles is a set of files, nested sets are referenced by their id
+type NamedSetOfFiles struct {
+	ID       string
+	Files    []File
+	FileSets []string
+}
+
+// BuildFinished is the last event about the build itself, with its exit code
+type BuildFinished struct {
+	ExitCode     ExitCode
+	ExitCodeName string
+	FinishTime   time.Time
+}
+
+// BuildMetrics holds the statistics of the actions of the build, sent after the build finished
+type BuildMetrics struct {
+	ActionsCreated  int
+	ActionsExecuted int
+	// Runners counts the executed actions by runner, i.e. "remote cache hit", "disk cache hit" or "linux-sandbox".
+	// The "total" runner counts all of them.
+	Runners map[string]int
+	// ActionCacheHits and ActionCacheMisses are about the local action cache, not the remote or disk cache
+	ActionCacheHits   int
+	ActionCacheMisses int
+	WallTime          time.Duration
+}
+
+// Aborted explains why an event was not produced, i.e. a target was skipped
+type Aborted struct {
+	Reason      string `json:"reason"`
+	Description string `json:"description"`
+}
+
+// jsonInt is an integer encoded either as JSON number or as string, proto3 JSON encodes int64 as string
+type jsonInt int64
+
+func (i *jsonInt) UnmarshalJSON(data []byte) error {
+	raw := string(bytes.Trim(data, `"`))
+	if raw == "" || raw == "null" {
+		return nil
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s: %w", data, err)
+	}
+	*i = jsonInt(value)
+	return nil
+}
+
+// jsonDuration is a google.protobuf.Duration encoded as JSON, i.e. "1.500s"
+type jsonDuration time.Duration
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	raw := string(bytes.Trim(data, `"`))
+	if raw == "" || raw == "null" {
+		return nil
+	}
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s: %w", data, err)
+	}
+	*d = jsonDuration(duration)
+	return nil
+}
+
+type jsonFailureDetail struct {
+	Message string `json:"message"`
+}
+
+type jsonBuildEvent struct {
+	ID          map[string]json.RawMessage `json:"id"`
+	LastMessage bool                       `json:"lastMessage"`
+	Completed   *struct {
+		Success       bool               `json:"success"`
+		OutputGroup   []OutputGroup      `json:"outputGroup"`
+		FailureDetail *jsonFailureDetail `json:"failureDetail"`
+	} `json:"completed"`
+	TestResult *struct {
+		Status                    TestStatus   `json:"status"`
+		CachedLocally             bool         `json:"cachedLocally"`
+		TestAttemptDurationMillis jsonInt      `json:"testAttemptDurationMillis"`
+		TestAttemptDuration       jsonDuration `json:"testAttemptDuration"`
+		TestActionOutput          []File       `json:"testActionOutput"`
+		ExecutionInfo             struct {
+			Strategy       string `json:"strategy"`
+			CachedRemotely bool   `json:"cachedRemotely"`
+		} `json:"executionInfo"`
+	} `json:"testResult"`
+	TestSummary *struct {
+		OverallStatus          TestStatus   `json:"overallStatus"`
+		TotalRunCount          int          `json:"totalRunCount"`
+		ShardCount             int          `json:"shardCount"`
+		AttemptCount           int          `json:"attemptCount"`
+		TotalNumCached         int          `json:"totalNumCached"`
+		Passed                 []File       `json:"passed"`
+		Failed                 []File       `json:"failed"`
+		TotalRunDurationMillis jsonInt      `json:"totalRunDurationMillis"`
+		TotalRunDuration       jsonDuration `json:"totalRunDuration"`
+	} `json:"testSummary"`
+	Action *struct {
+		Success       bool               `json:"success"`
+		Type          string             `json:"type"`
+		ExitCode      int                `json:"exitCode"`
+		PrimaryOutput *File              `json:"primaryOutput"`
+		Stdout        *File              `json:"stdout"`
+		Stderr        *File              `json:"stderr"`
+		CommandLine   []string           `json:"commandLine"`
+		FailureDetail *jsonFailureDetail `json:"failureDetail"`
+	} `json:"action"`
+	NamedSetOfFiles *struct {
+		Files    []File `json:"files"`
+		FileSets []struct {
+			ID string `json:"id"`
+		} `json:"fileSets"`
+	} `json:"namedSetOfFiles"`
+	Finished *struct {
+		ExitCode *struct {
+			Name string `json:"name"`
+			Code int    `json:"code"`
+		} `json:"exitCode"`
+		FinishTimeMillis jsonInt `json:"finishTimeMillis"`
+	} `json:"finished"`
+	BuildMetrics *struct {
+		ActionSummary struct {
+			ActionsCreated  jsonInt `json:"actionsCreated"`
+			ActionsExecuted jsonInt `json:"actionsExecuted"`
+			RunnerCount     []struct {
+				Name  string  `json:"name"`
+				Count jsonInt `json:"count"`
+			} `json:"runnerCount"`
+			ActionCacheStatistics struct {
+				Hits   jsonInt `json:"hits"`
+				Misses jsonInt `json:"misses"`
+			} `json:"actionCacheStatistics"`
+		} `json:"actionSummary"`
+		TimingMetrics struct {
+			WallTimeInMs jsonInt `json:"wallTimeInMs"`
+		} `json:"timingMetrics"`
+	} `json:"buildMetrics"`
+	Aborted *Aborted `json:"aborted"`
+}
+
+// jsonEventID holds the fields of the different event ids the typed events need
+type jsonEventID struct {
+	Label   string `json:"label"`
+	Aspect  string `json:"aspect"`
+	ID      string `json:"id"`
+	Run     int    `json:"run"`
+	Shard   int    `json:"shard"`
+	Attempt int    `json:"attempt"`
+}
+
+// DecodeBuildEvent decodes a single JSON encoded build event
+func DecodeBuildEvent(data []byte) (BuildEvent, error) {
+	var raw jsonBuildEvent
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return BuildEvent{}, fmt.Errorf("error decoding build event: %w", err)
+	}
+	event := BuildEvent{LastMessage: raw.LastMessage, Raw: append(json.RawMessage{}, data...)}
+	var id jsonEventID
+	for kind, rawID := range raw.ID {
+		event.Kind = kind
+		if err := json.Unmarshal(rawID, &id); err != nil {
+			return BuildEvent{}, fmt.Errorf("error decoding build event id %s: %w", kind, err)
+		}
+	}
+	event.Label = id.Label
+	event.Aspect = id.Aspect
+	event.Aborted = raw.Aborted
+
+	switch {
+	case raw.Completed != nil:
+		event.TargetCompleted = &TargetCompleted{Success: raw.Completed.Success, OutputGroups: raw.Completed.OutputGroup}
+		if raw.Completed.FailureDetail != nil {
+			event.TargetCompleted.FailureMessage = raw.Completed.FailureDetail.Message
+		}
+	case raw.TestResult != nil:
+		result := raw.TestResult
+		event.TestResult = &TestResult{
+			Run:            id.Run,
+			Shard:          id.Shard,
+			Attempt:        id.Attempt,
+			Status:         result.Status,
+			CachedLocally:  result.CachedLocally,
+			CachedRemotely: result.ExecutionInfo.CachedRemotely,
+			Strategy:       result.ExecutionInfo.Strategy,
+			Duration:       time.Duration(result.TestAttemptDuration),
+			Outputs:        result.TestActionOutput,
+		}
+		if event.TestResult.Duration == 0 {
+			event.TestResult.Duration = time.Duration(result.TestAttemptDurationMillis) * time.Millisecond
+		}
+	case raw.TestSummary != nil:
+		summary := raw.TestSummary
+		event.TestSummary = &TestSummary{
+			OverallStatus:  summary.OverallStatus,
+			TotalRunCount:  summary.TotalRunCount,
+			ShardCount:     summary.ShardCount,
+			AttemptCount:   summary.AttemptCount,
+			TotalNumCached: summary.TotalNumCached,
+			Passed:         summary.Passed,
+			Failed:         summary.Failed,
+			Duration:       time.Duration(summary.TotalRunDuration),
+		}
+		if event.TestSummary.Duration == 0 {
+			event.TestSummary.Duration = time.Duration(summary.TotalRunDurationMillis) * time.Millisecond
+		}
+	case raw.Action != nil:
+		action := raw.Action
+		event.ActionCompleted = &ActionCompleted{
+			Success:     action.Success,
+			Mnemonic:    action.Type,
+			ExitCode:    action.ExitCode,
+			Stdout:      action.Stdout,
+			Stderr:      action.Stderr,
+			CommandLine: action.CommandLine,
+		}
+		if action.PrimaryOutput != nil {
+			event.ActionCompleted.PrimaryOutput = action.PrimaryOutput.Name
+		}
+		if action.FailureDetail != nil {
+			event.ActionCompleted.FailureMessage = action.FailureDetail.Message
+		}
+	case raw.NamedSetOfFiles != nil:
+		event.NamedSetOfFiles = &NamedSetOfFiles{ID: id.ID, Files: raw.NamedSetOfFiles.Files}
+		for _, fileSet := range raw.NamedSetOfFiles.FileSets {
+			event.NamedSetOfFiles.FileSets = append(event.NamedSetOfFiles.FileSets, fileSet.ID)
+		}
+	case raw.Finished != nil:
+		event.BuildFinished = &BuildFinished{}
+		if raw.Finished.ExitCode != nil {
+			event.BuildFinished.ExitCode = ExitCode(raw.Finished.ExitCode.Code)
+			event.BuildFinished.ExitCodeName = raw.Finished.ExitCode.Name
+		}
+		if raw.Finished.FinishTimeMillis != 0 {
+			event.BuildFinished.FinishTime = time.UnixMilli(int64(raw.Finished.FinishTimeMillis)).UTC()
+		}
+	case raw.BuildMetrics != nil:
+		summary := raw.BuildMetrics.ActionSummary
+		event.BuildMetrics = &BuildMetrics{
+			ActionsCreated:    int(summary.ActionsCreated),
+			ActionsExecuted:   int(summary.ActionsExecuted),
+			Runners:           map[string]int{},
+			ActionCacheHits:   int(summary.ActionCacheStatistics.Hits),
+			ActionCacheMisses: int(summary.ActionCacheStatistics.Misses),
+			WallTime:          time.Duration(raw.BuildMetrics.TimingMetrics.WallTimeInMs) * time.Millisecond,
+		}
+		for _, runner := range summary.RunnerCount {
+			event.BuildMetrics.Runners[runner.Name] += int(runner.Count)
+		}
+	}
+	return event, nil
+}
+
+// ParseBuildEvents parses a file written with --build_event_json_file, one JSON event per line
+func ParseBuildEvents(r io.Reader) ([]BuildEvent, error) {
+	var events []BuildEvent
+	scanner := bufio.NewScanner(r)
+	// Events listing many files or long command lines easily exceed the default token size
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		event, err := DecodeBuildEvent(line)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return events, fmt.Errorf("error reading build events: %w", err)
+	}
+	return events, nil
+}
+
+// TargetOutcome is the result of building a single target
+type TargetOutcome struct {
+	Label   string
+	Success bool
+	// Aborted is set instead of Success when the target was skipped, i.e. after a failure without --keep_going
+	Aborted *Aborted
+	// FailureMessage describes why the target failed, if it did
+	FailureMessage string
+	// Outputs lists the files of the default output group
+	Outputs []File
+
+	outputGroups []OutputGroup
+}
+
+// BuildSummary aggregates the build events of a build or test invocation
+type BuildSummary struct {
+	// Finished is the buildFinished event, nil if the stream ended before it
+	Finished *BuildFinished
+	// Targets are the outcomes of the requested targets by label, merged across the configurations they were built in
+	Targets map[string]*TargetOutcome
+	// Tests are the test summaries, by label
+	Tests map[string]*TestSummary
+	// TestResults are the individual test attempts, by label
+	TestResults map[string][]*TestResult
+	// FailedActions are the actions that failed, with the label of their target
+	FailedActions map[string][]*ActionCompleted
+	// Metrics is the buildMetrics event, nil if the stream ended before it
+	Metrics *BuildMetrics
+	// Result holds the outputs of the Bazel command when run through a Client
+	Result *Result
+
+	namedSets map[string]*NamedSetOfFiles
+}
+
+// SummarizeBuildEvents aggregates the outcome of targets and tests from build events
+func SummarizeBuildEvents(events []BuildEvent) *BuildSummary {
+	summary := newBuildSummary()
+	for i := range events {
+		summary.add(&events[i])
+	}
+	summary.resolveOutputs()
+	return summary
+}
+
+func newBuildSummary() *BuildSummary {
+	return &BuildSummary{
+		Targets:       map[string]*TargetOutcome{},
+		Tests:         map[string]*TestSummary{},
+		TestResults:   map[string][]*TestResult{},
+		FailedActions: map[string][]*ActionCompleted{},
+		namedSets:     map[string]*NamedSetOfFiles{},
+	}
+}
+
+func (s *BuildSummary) add(event *BuildEvent) {
+	switch {
+	case event.Kind == "targetCompleted" && event.Aspect != "":
+		// The outcome of an aspect applied to the target is not the one of the target
+	case event.Kind == "targetCompleted" && event.TargetCompleted != nil:
+		completed := event.TargetCompleted
+		// Outputs are resolved once all named sets are known
+		s.addTarget(&TargetOutcome{
+			Label:          event.Label,
+			Success:        completed.Success,
+			FailureMessage: completed.FailureMessage,
+			outputGroups:   completed.OutputGroups,
+		})
+	case event.Kind == "targetCompleted" && event.Aborted != nil:
+		s.addTarget(&TargetOutcome{Label: event.Label, Aborted: event.Aborted})
+	case event.TestResult != nil:
+		s.TestResults[event.Label] = append(s.TestResults[event.Label], event.TestResult)
+	case event.TestSummary != nil:
+		s.Tests[event.Label] = event.TestSummary
+	case event.ActionCompleted != nil && !event.ActionCompleted.Success:
+		s.FailedActions[event.Label] = append(s.FailedActions[event.Label], event.ActionCompleted)
+	case event.NamedSetOfFiles != nil:
+		s.namedSets[event.NamedSetOfFiles.ID] = event.NamedSetOfFiles
+	case event.BuildFinished != nil:
+		s.Finished = event.BuildFinished
+	case event.BuildMetrics != nil:
+		s.Metrics = event.BuildMetrics
+	}
+}
+
+// addTarget records the outcome of a target, which completes once per configuration it is built in:
+// the outputs of all configurations are kept and a failure wins over an abort, which wins over a success.
+func (s *BuildSummary) addTarget(outcome *TargetOutcome) {
+	existing, ok := s.Targets[outcome.Label]
+	if !ok {
+		s.Targets[outcome.Label] = outcome
+		return
+	}
+	existing.outputGroups = append(existing.outputGroups, outcome.outputGroups...)
+	if outcomeRank(outcome) > outcomeRank(existing) {
+		existing.Success = outcome.Success
+		existing.Aborted = outcome.Aborted
+		existing.FailureMessage = outcome.FailureMessage
+	}
+}
+
+// outcomeRank orders the outcomes of a target: success, aborted then failed
+func outcomeRank(outcome *TargetOutcome) int {
+	switch {
+	case outcome.Success:
+		return 0
+	case outcome.Aborted != nil:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// outputFiles expands the named sets of the default output group
+func (s *BuildSummary) outputFiles(groups []OutputGroup) []File {
+	var files []File
+	seen := map[string]bool{}
+	var expand func(id string)
+	expand = func(id string) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		set, ok := s.namedSets[id]
+		if !ok {
+			return
+		}
+		files = append(files, set.Files...)
+		for _, nested := range set.FileSets {
+			expand(nested)
+		}
+	}
+	for _, group := range groups {
+		if group.Name != "default" {
+			continue
+		}
+		for _, fileSet := range group.FileSets {
+			expand(fileSet.ID)
+		}
+	}
+	return files
+}
+
+// resolveOutputs fills the outputs of the targets from the named sets of files
+func (s *BuildSummary) resolveOutputs() {
+	for _, outcome := range s.Targets {
+		outcome.Outputs = s.outputFiles(outcome.outputGroups)
+	}
+}
+
+// Success returns true if the build finished with exit code 0
+func (s *BuildSummary) Success() bool {
+	return s.Finished != nil && s.Finished.ExitCode == ExitSuccess
+}
+
+// TargetsWithStatus returns the sorted labels of the targets built successfully (true) or not (false)
+func (s *BuildSummary) TargetsWithStatus(success bool) []string {
+	var labels []string
+	for label, outcome := range s.Targets {
+		if outcome.Success == success {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// TestsWithStatus returns the sorted labels of the tests with the given overall status
+func (s *BuildSummary) TestsWithStatus(status TestStatus) []string {
+	var labels []string
+	for label, summary := range s.Tests {
+		if summary.OverallStatus == status {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// CachedTests returns the sorted labels of the tests whose runs were all taken from a cache
+func (s *BuildSummary) CachedTests() []string {
+	var labels []string
+	for label, summary := range s.Tests {
+		if summary.TotalRunCount > 0 && summary.TotalNumCached >= summary.TotalRunCount {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
@@ -0,0 +1,166 @@
+package bazel
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBuildEvents(t *testing.T) {
+	events, err := ParseBuildEvents(openTestFile(t, "bep.json"))
+	require.NoError(t, err)
+	require.Len(t, events, 14)
+
+	assert.Equal(t, "started", events[0].Kind)
+	assert.Nil(t, events[0].TargetCompleted)
+
+	completed := events[3]
+	assert.Equal(t, "targetCompleted", completed.Kind)
+	assert.Equal(t, "//cmd/tool:tool", completed.Label)
+	require.NotNil(t, completed.TargetCompleted)
+	assert.True(t, completed.TargetCompleted.Success)
+
+	action := events[4].ActionCompleted
+	require.NotNil(t, action)
+	assert.False(t, action.Success)
+	assert.Equal(t, "GoCompilePkg", action.Mnemonic)
+	assert.Equal(t, 1, action.ExitCode)
+	assert.Equal(t, "pkg/broken/broken.a", action.PrimaryOutput)
+	assert.Equal(t, "file:///output/actions/stderr-1", action.Stderr.URI)
+
+	aborted := events[6]
+	assert.Equal(t, "targetCompleted", aborted.Kind)
+	assert.Nil(t, aborted.TargetCompleted)
+	assert.Equal(t, "SKIPPED", aborted.Aborted.Reason)
+
+	firstAttempt, secondAttempt := events[7].TestResult, events[8].TestResult
+	assert.Equal(t, TestFailed, firstAttempt.Status)
+	assert.Equal(t, 1200*time.Millisecond, firstAttempt.Duration)
+	assert.Equal(t, 2, secondAttempt.Attempt)
+	assert.Equal(t, 800*time.Millisecond, secondAttempt.Duration)
+	assert.Equal(t, "linux-sandbox", secondAttempt.Strategy)
+	assert.True(t, events[10].TestResult.CachedRemotely)
+
+	finished := events[12].BuildFinished
+	assert.Equal(t, ExitBuildFailure, finished.ExitCode)
+	assert.Equal(t, "BUILD_FAILURE", finished.ExitCodeName)
+	assert.Equal(t, time.Date(2023, 11, 14, 22, 13, 24, 0, time.UTC), finished.FinishTime)
+	assert.True(t, events[13].LastMessage)
+}
+
+func TestParseBuildMetrics(t *testing.T) {
+	events, err := ParseBuildEvents(openTestFile(t, "bep_metrics.json"))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	metrics := events[1].BuildMetrics
+	require.NotNil(t, metrics)
+	assert.Equal(t, 42, metrics.ActionsCreated)
+	assert.Equal(t, 12, metrics.ActionsExecuted)
+	assert.Equal(t, 6, metrics.Runners["remote cache hit"])
+	assert.Equal(t, 5, metrics.ActionCacheHits)
+	assert.Equal(t, 7, metrics.ActionCacheMisses)
+	assert.Equal(t, 4200*time.Millisecond, metrics.WallTime)
+	assert.Equal(t, metrics, SummarizeBuildEvents(events).Metrics)
+}
+
+func TestParseBuildEventsInvalid(t *testing.T) {
+	events, err := ParseBuildEvents(strings.NewReader("{\"id\":{\"started\":{}}}\n{not json}\n"))
+	assert.Error(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestSummarizeBuildEvents(t *testing.T) {
+	events, err := ParseBuildEvents(openTestFile(t, "bep.json"))
+	require.NoError(t, err)
+	summary := SummarizeBuildEvents(events)
+
+	assert.False(t, summary.Success())
+	assert.Equal(t, []string{"//cmd/tool:tool"}, summary.TargetsWithStatus(true))
+	assert.Equal(t, []string{"//pkg/broken:broken", "//pkg/broken:broken_test"}, summary.TargetsWithStatus(false))
+	assert.Equal(t, "SKIPPED", summary.Targets["//pkg/broken:broken_test"].Aborted.Reason)
+	assert.Contains(t, summary.Targets["//pkg/broken:broken"].FailureMessage, "builder failed")
+	assert.Equal(t, []string{"//pkg/flaky:flaky_test"}, summary.TestsWithStatus(TestFlaky))
+	assert.Equal(t, []string{"//pkg/util:util_test"}, summary.TestsWithStatus(TestPassed))
+	assert.Equal(t, []string{"//pkg/util:util_test"}, summary.CachedTests())
+	assert.Len(t, summary.TestResults["//pkg/flaky:flaky_test"], 2)
+	assert.Len(t, summary.FailedActions["//pkg/broken:broken"], 1)
+
+	var outputs []string
+	for _, file := range summary.Targets["//cmd/tool:tool"].Outputs {
+		outputs = append(outputs, file.Name)
+	}
+	assert.Equal(t, []string{"cmd/tool/tool.runfiles_manifest", "cmd/tool/tool_/tool"}, outputs)
+}
+
+func TestSummarizeBuildEventsConfigurationsAndAspects(t *testing.T) {
+	events, err := ParseBuildEvents(openTestFile(t, "bep_aspect.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "//tools:lint.bzl%lint", events[4].Aspect)
+	assert.Empty(t, events[3].Aspect)
+	summary := SummarizeBuildEvents(events)
+
+	assert.Equal(t, []string{"//pkg/lib:lib", "//pkg/util:util"}, summary.TargetsWithStatus(true), "Expected aspect failures to be ignored")
+	assert.Equal(t, []string{"//pkg/app:app"}, summary.TargetsWithStatus(false), "Expected a failure in any configuration to win")
+	assert.Contains(t, summary.Targets["//pkg/app:app"].FailureMessage, "builder failed")
+
+	var outputs []string
+	for _, file := range summary.Targets["//pkg/lib:lib"].Outputs {
+		outputs = append(outputs, file.URI)
+	}
+	assert.Equal(t, []string{
+		"file:///workspace/bazel-out/k8-fastbuild/bin/pkg/lib/lib.a",
+		"file:///workspace/bazel-out/k8-opt-exec/bin/pkg/lib/lib.a",
+	}, outputs, "Expected the outputs of all configurations without the ones of the aspect")
+}
+
+func TestClientTestWithEvents(t *testing.T) {
+	client := NewClient(t.TempDir())
+	client.Env = []string{"BEP_FIXTURE=" + filepath.Join(getTestDir(), "bep.json")}
+	// Write the events in two steps to check they are followed while the command runs
+	client.Binary = writeScript(t, `for arg in "$@"; do
+  case "$arg" in --build_event_json_file=*) out="${arg#*=}";; esac
+done
+head -n 4 "$BEP_FIXTURE" > "$out"
+sleep 0.2
+tail -n +5 "$BEP_FIXTURE" >> "$out"
+exit 3`)
+
+	events := make(chan BuildEvent)
+	var kinds []string
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for event := range events {
+			kinds = append(kinds, event.Kind)
+		}
+	}()
+
+	summary, err := client.TestWithEvents([]string{"//..."}, []string{"--keep_going"}, events)
+	<-received
+	var exitErr *ExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, ExitTestsFailed, exitErr.ExitCode)
+	assert.Len(t, kinds, 14)
+	assert.Equal(t, "buildToolLogs", kinds[13])
+	assert.Equal(t, []string{"//pkg/flaky:flaky_test"}, summary.TestsWithStatus(TestFlaky))
+	assert.Equal(t, 3, summary.Result.ExitCode)
+	assert.Equal(t, "--keep_going", summary.Result.Args[3])
+}
+
+func TestClientBuildWithEventsNoEvents(t *testing.T) {
+	client := NewClient(t.TempDir())
+	client.Binary = writeScript(t, `echo "ERROR: Unrecognized option: --nope" >&2; exit 2`)
+
+	summary, err := client.BuildWithEvents([]string{"//..."}, []string{"--nope"}, nil)
+	var exitErr *ExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, ExitCommandLineError, exitErr.ExitCode)
+	assert.Empty(t, summary.Targets)
+	assert.Nil(t, summary.Finished)
+}
@@ -0,0 +1,133 @@
+package bazel
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// buildEventsPollInterval is how often the build event file is checked for new events
+var buildEventsPollInterval = 50 * time.Millisecond
+
+// BuildWithEvents builds the given target patterns and returns the summary of the build events.
+// If events is not nil, each event is sent as soon as Bazel writes it and the channel is closed when
+// the build ended, it must be consumed for the build to complete.
+func (c *Client) BuildWithEvents(targets, flags []string, events chan<- BuildEvent) (*BuildSummary, error) {
+	return c.runWithEvents("build", targets, flags, events)
+}
+
+// TestWithEvents runs the tests matching the given target patterns and returns the summary of the
+// build events, see BuildWithEvents. Failing tests are reported as an *ExitError with ExitTestsFailed,
+// together with the summary.
+func (c *Client) TestWithEvents(targets, flags []string, events chan<- BuildEvent) (*BuildSummary, error) {
+	return c.runWithEvents("test", targets, flags, events)
+}
+
+func (c *Client) runWithEvents(command string, targets, flags []string, events chan<- BuildEvent) (*BuildSummary, error) {
+	if events != nil {
+		defer close(events)
+	}
+	dir, err := os.MkdirTemp("", "bazelshell-bep-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating build event directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	eventsFile := filepath.Join(dir, "events.json")
+
+	var result *Result
+	var runErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		args := append([]string{"--build_event_json_file=" + eventsFile}, flags...)
+		result, runErr = c.Run(command, targetArgs(targets, args)...)
+	}()
+
+	summary := newBuildSummary()
+	followErr := followBuildEvents(eventsFile, done, func(event BuildEvent) {
+		summary.add(&event)
+		if events != nil {
+			events <- event
+		}
+	})
+	<-done
+	summary.resolveOutputs()
+	summary.Result = result
+	if runErr != nil {
+		return summary, runErr
+	}
+	return summary, followErr
+}
+
+// followBuildEvents reads the events of a build event file while Bazel writes it,
+// until the last message or until done is closed and the whole file was read.
+func followBuildEvents(path string, done <-chan struct{}, handle func(BuildEvent)) error {
+	file, err := waitForFile(path, done)
+	if err != nil || file == nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var pending []byte
+	finished := false
+	for {
+		chunk, err := reader.ReadBytes('\n')
+		pending = append(pending, chunk...)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("error reading build events: %w", err)
+		}
+		// Lines are only complete once terminated, or once Bazel exited
+		if err == nil || (finished && len(pending) > 0) {
+			line := bytes.TrimSpace(pending)
+			pending = pending[:0]
+			if len(line) == 0 {
+				continue
+			}
+			event, err := DecodeBuildEvent(line)
+			if err != nil {
+				return err
+			}
+			handle(event)
+			if event.LastMessage {
+				return nil
+			}
+			continue
+		}
+		if finished {
+			return nil
+		}
+		select {
+		case <-done:
+			finished = true
+		case <-time.After(buildEventsPollInterval):
+		}
+	}
+}
+
+// waitForFile opens the file as soon as it exists, nil if it still does not once done is closed
+func waitForFile(path string, done <-chan struct{}) (*os.File, error) {
+	for {
+		file, err := os.Open(path)
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error opening build events: %w", err)
+		}
+		select {
+		case <-done:
+			// Bazel exited, i.e. on a command line error, one last chance in case it wrote the file meanwhile
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, nil
+			}
+			return file, nil
+		case <-time.After(buildEventsPollInterval):
+		}
+	}
+}
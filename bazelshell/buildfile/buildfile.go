@@ -0,0 +1,103 @@
+// Package buildfile reads and edits BUILD, .bzl and MODULE.bazel files without Bazel.
+// Files are parsed and formatted by github.com/bazelbuild/buildtools/build, the library behind buildifier:
+// rules are found with File.Rules and File.RuleNamed and edited with Rule.SetAttr and Rule.DelAttr,
+// this package adds the edits of list attributes and load statements.
+package buildfile
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// ParseFile reads and parses a file, its type (BUILD, .bzl, MODULE.bazel, WORKSPACE) being given by its name
+func ParseFile(path string) (*build.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return build.Parse(path, data)
+}
+
+// WriteFile formats the file like buildifier and writes it back to its path
+func WriteFile(f *build.File) error {
+	if err := os.WriteFile(f.Path, build.Format(f), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// AddToList adds strings to a list attribute of a rule, creating it if needed. Values already in the list are
+// skipped, the others appended: build.Format sorts the lists buildifier sorts, i.e. srcs and deps in BUILD files.
+// It fails if the attribute is not a plain list, i.e. a glob() or a select().
+func AddToList(r *build.Rule, key string, values ...string) error {
+	value := r.Attr(key)
+	if value == nil {
+		value = &build.ListExpr{}
+		r.SetAttr(key, value)
+	}
+	list, ok := value.(*build.ListExpr)
+	if !ok {
+		return fmt.Errorf("attribute %s of %s is not a list", key, r.Name())
+	}
+	for _, v := range values {
+		if indexOfString(list, v) < 0 {
+			list.List = append(list.List, &build.StringExpr{Value: v})
+		}
+	}
+	return nil
+}
+
+// RemoveFromList removes a string from a list attribute of a rule, it returns false if it was not in the list
+func RemoveFromList(r *build.Rule, key, value string) bool {
+	list, ok := r.Attr(key).(*build.ListExpr)
+	if !ok {
+		return false
+	}
+	i := indexOfString(list, value)
+	if i < 0 {
+		return false
+	}
+	list.List = append(list.List[:i], list.List[i+1:]...)
+	return true
+}
+
+// indexOfString returns the index of a string literal in a list, -1 if it is not there
+func indexOfString(list *build.ListExpr, value string) int {
+	for i, x := range list.List {
+		if str, ok := x.(*build.StringExpr); ok && str.Value == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddLoad makes sure the symbols are loaded from the module, adding them to an existing
+// load of the module or adding a load statement after the existing ones.
+func AddLoad(f *build.File, module string, symbols ...string) {
+	var load *build.LoadStmt
+	insertAt := 0
+	for i, stmt := range f.Stmt {
+		if existing, ok := stmt.(*build.LoadStmt); ok {
+			insertAt = i + 1
+			if existing.Module.Value == module {
+				load = existing
+			}
+		}
+	}
+	if load == nil {
+		load = &build.LoadStmt{Module: &build.StringExpr{Value: module}, ForceCompact: true}
+		f.Stmt = append(f.Stmt[:insertAt], append([]build.Expr{load}, f.Stmt[insertAt:]...)...)
+	}
+	for _, symbol := range symbols {
+		found := false
+		for _, to := range load.To {
+			found = found || to.Name == symbol
+		}
+		if !found {
+			load.From = append(load.From, &build.Ident{Name: symbol})
+			load.To = append(load.To, &build.Ident{Name: symbol})
+		}
+	}
+}
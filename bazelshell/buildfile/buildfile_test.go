@@ -0,0 +1,86 @@
+package buildfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazelbuild/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const editInput = `load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "lib",
+    srcs = [
+        "a.go",
+        "c.go",  # keep me
+    ],
+    deps = ["//z", "//a"],
+    visibility = ["//visibility:public"],
+)
+
+go_test(
+    name = "lib_test",
+    srcs = glob(["*_test.go"]),
+)
+`
+
+func TestEditRules(t *testing.T) {
+	f, err := build.Parse("pkg/BUILD.bazel", []byte(editInput))
+	require.NoError(t, err)
+	lib := f.RuleNamed("lib")
+	require.NotNil(t, lib)
+
+	require.NoError(t, AddToList(lib, "srcs", "b.go", "a.go", "d.go"))
+	require.NoError(t, AddToList(lib, "data", "testdata/input.txt"))
+	assert.True(t, RemoveFromList(lib, "deps", "//z"))
+	assert.False(t, RemoveFromList(lib, "deps", "//z"))
+	assert.False(t, RemoveFromList(lib, "missing", "//z"))
+	assert.Error(t, AddToList(f.RuleNamed("lib_test"), "srcs", "x_test.go"), "Expected a glob not to be edited")
+	AddLoad(f, "@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+	AddLoad(f, "//tools:defs.bzl", "my_rule")
+
+	expected := `load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+load("//tools:defs.bzl", "my_rule")
+
+go_library(
+    name = "lib",
+    srcs = [
+        "a.go",
+        "b.go",
+        "c.go",  # keep me
+        "d.go",
+    ],
+    data = ["testdata/input.txt"],
+    visibility = ["//visibility:public"],
+    deps = ["//a"],
+)
+
+go_test(
+    name = "lib_test",
+    srcs = glob(["*_test.go"]),
+)
+`
+	assert.Equal(t, expected, string(build.Format(f)))
+}
+
+func TestParseAndWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "MODULE.bazel")
+	require.NoError(t, os.WriteFile(path, []byte(`bazel_dep(name = "rules_go", version = "0.41.0")`+"\n"), 0644))
+
+	f, err := ParseFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, build.TypeModule, f.Type)
+	f.Rules("bazel_dep")[0].SetAttr("version", &build.StringExpr{Value: "0.42.0"})
+	require.NoError(t, WriteFile(f))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `bazel_dep(name = "rules_go", version = "0.42.0")`+"\n", string(data))
+
+	_, err = ParseFile(filepath.Join(t.TempDir(), "BUILD"))
+	assert.Error(t, err)
+}
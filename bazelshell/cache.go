@@ -0,0 +1,250 @@
+package bazel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-ch/go-libs/gitshell"
+)
+
+// QueryCache stores query results on disk, see Client.QueryCache.
+// Entries are keyed by the command line and the content of the workspace: the git tree of the workspace in HEAD
+// and its .bazelrc files if the working tree is clean, otherwise the BUILD and .bzl files and the list of files.
+// Settings outside of the workspace, such as the user .bazelrc, are not part of the key.
+type QueryCache struct {
+	// Dir is the directory the entries are stored in
+	Dir string
+	// TTL is how long entries are used, they never expire if 0
+	TTL time.Duration
+}
+
+// NewQueryCache returns a cache storing its entries in dir
+func NewQueryCache(dir string, ttl time.Duration) *QueryCache {
+	return &QueryCache{Dir: dir, TTL: ttl}
+}
+
+// DefaultQueryCacheDir returns the directory for query results within the user cache directory
+func DefaultQueryCacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error resolving user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "go-libs", "bazel-query"), nil
+}
+
+// Get returns the entry for key, false if there is none or it expired
+func (q *QueryCache) Get(key string) ([]byte, bool) {
+	path := q.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if q.expired(info) {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Put stores the entry for key, replacing any previous one
+func (q *QueryCache) Put(key string, data []byte) error {
+	if err := os.MkdirAll(q.Dir, 0755); err != nil {
+		return fmt.Errorf("error creating query cache %s: %w", q.Dir, err)
+	}
+	// Written to a temporary file first so that concurrent readers never see partial entries
+	tmp, err := os.CreateTemp(q.Dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error writing query cache entry: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), q.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("error writing query cache entry: %w", err)
+	}
+	return nil
+}
+
+// Invalidate removes all the entries
+func (q *QueryCache) Invalidate() error {
+	if err := os.RemoveAll(q.Dir); err != nil {
+		return fmt.Errorf("error removing query cache %s: %w", q.Dir, err)
+	}
+	return nil
+}
+
+// Prune removes the expired entries
+func (q *QueryCache) Prune() error {
+	entries, err := os.ReadDir(q.Dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error listing query cache %s: %w", q.Dir, err)
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !q.expired(info) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(q.Dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error pruning query cache: %w", err)
+		}
+	}
+	return nil
+}
+
+func (q *QueryCache) path(key string) string {
+	return filepath.Join(q.Dir, key)
+}
+
+func (q *QueryCache) expired(info fs.FileInfo) bool {
+	return q.TTL > 0 && time.Since(info.ModTime()) > q.TTL
+}
+
+// queryCacheKey returns the key of a query: a hash of the command line and of the workspace content
+func (c *Client) queryCacheKey(args []string) (string, error) {
+	content, err := WorkspaceContentHash(c.WorkspaceDir)
+	if err != nil {
+		return "", err
+	}
+	workspace, err := filepath.Abs(c.WorkspaceDir)
+	if err != nil {
+		return "", fmt.Errorf("error resolving workspace %s: %w", c.WorkspaceDir, err)
+	}
+	// Identical trees in different directories have different output bases and absolute paths in results
+	parts := []string{content, workspace, c.Binary}
+	parts = append(parts, c.StartupOptions...)
+	parts = append(parts, c.Env...)
+	parts = append(parts, args...)
+	hash := sha256.New()
+	for _, part := range parts {
+		// Null separated so that the parts cannot be confused
+		fmt.Fprintf(hash, "%s\x00", part)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// WorkspaceContentHash returns a hash of what query results depend on in the workspace.
+// If the workspace is in a git repository with a clean working tree, it is the hash of the tree of the
+// workspace directory in HEAD, the workspace may be a subdirectory of the repository, along with the content of the
+// .bazelrc files at its root as they are often ignored by git (i.e. a user.bazelrc loaded with try-import).
+// Otherwise it is computed from the paths of all the files and the content of the BUILD, .bzl, MODULE.bazel,
+// WORKSPACE and .bazelrc files: sources only matter to queries through globs.
+// Other files ignored by git are not seen on a clean working tree, even if Bazel reads them.
+func WorkspaceContentHash(workspace string) (string, error) {
+	if gitRoot, err := gitshell.GitResolveRoot(workspace); err == nil {
+		status, err := gitshell.GitStatus(workspace)
+		if err != nil {
+			return "", fmt.Errorf("error reading git status of %s: %w", workspace, err)
+		}
+		if len(status) == 0 {
+			absWorkspace, err := filepath.Abs(workspace)
+			if err != nil {
+				return "", fmt.Errorf("error resolving workspace %s: %w", workspace, err)
+			}
+			prefix, err := workspacePrefix(gitRoot, absWorkspace)
+			if err != nil {
+				return "", err
+			}
+			tree, err := gitshell.GitResolveRevision(workspace, "HEAD:"+prefix)
+			if err == nil {
+				rcFiles, err := hashBazelrcFiles(workspace)
+				if err != nil {
+					return "", err
+				}
+				return "git:" + tree + ":" + rcFiles, nil
+			}
+			// Repositories without commits are hashed as files
+		}
+	}
+	return hashBuildFiles(workspace)
+}
+
+// hashBazelrcFiles hashes the names and content of the .bazelrc files at the root of the workspace
+func hashBazelrcFiles(workspace string) (string, error) {
+	entries, err := os.ReadDir(workspace)
+	if err != nil {
+		return "", fmt.Errorf("error listing files of %s: %w", workspace, err)
+	}
+	hash := sha256.New()
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".bazelrc") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(workspace, entry.Name()))
+		if err != nil {
+			return "", fmt.Errorf("error hashing %s: %w", entry.Name(), err)
+		}
+		fmt.Fprintf(hash, "%s\x00%s\x00", entry.Name(), content)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashBuildFiles hashes the paths of the files of the workspace and the content of the files queries read
+func hashBuildFiles(workspace string) (string, error) {
+	var paths []string
+	err := filepath.WalkDir(workspace, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && entry.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		// Convenience symlinks such as bazel-bin are not followed
+		if !entry.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error listing files of %s: %w", workspace, err)
+	}
+	sort.Strings(paths)
+	hash := sha256.New()
+	for _, path := range paths {
+		rel, _ := filepath.Rel(workspace, path)
+		fmt.Fprintf(hash, "%s\x00", filepath.ToSlash(rel))
+		if !isBuildFile(filepath.Base(path)) {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("error hashing %s: %w", path, err)
+		}
+		_, err = io.Copy(hash, f)
+		_ = f.Close()
+		if err != nil {
+			return "", fmt.Errorf("error hashing %s: %w", path, err)
+		}
+		hash.Write([]byte{0})
+	}
+	return "files:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// isBuildFile returns true for the files whose content query results depend on
+func isBuildFile(name string) bool {
+	switch name {
+	case "BUILD", "BUILD.bazel", "MODULE.bazel", "MODULE.bazel.lock", "REPO.bazel", "WORKSPACE", "WORKSPACE.bazel",
+		"WORKSPACE.bzlmod", ".bazelversion":
+		return true
+	}
+	return strings.HasSuffix(name, ".bzl") || strings.HasSuffix(name, ".bazelrc")
+}
@@ -0,0 +1,160 @@
+package bazel
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/gitshell"
+	"github.com/open-ch/go-libs/gitshell/gitshelltest"
+)
+
+func TestQueryCache(t *testing.T) {
+	cache := NewQueryCache(filepath.Join(t.TempDir(), "cache"), time.Hour)
+
+	_, ok := cache.Get("key")
+	assert.False(t, ok)
+	require.NoError(t, cache.Put("key", []byte("//:a\n")))
+	require.NoError(t, cache.Put("other", []byte("//:b\n")))
+	data, ok := cache.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "//:a\n", string(data))
+
+	// Expire the first entry
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(cache.Dir, "key"), old, old))
+	require.NoError(t, cache.Prune())
+	_, ok = cache.Get("key")
+	assert.False(t, ok)
+	_, ok = cache.Get("other")
+	assert.True(t, ok)
+
+	require.NoError(t, cache.Invalidate())
+	_, ok = cache.Get("other")
+	assert.False(t, ok)
+	assert.NoError(t, cache.Prune(), "pruning a missing cache is a no-op")
+}
+
+func TestWorkspaceContentHash(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{Steps: []gitshelltest.Step{
+		gitshelltest.Commit{Message: "initial", Files: map[string]string{
+			".gitignore":     "user.bazelrc\n",
+			"MODULE.bazel":   "",
+			"lib/BUILD":      "go_library(name = 'lib')",
+			"lib/lib.go":     "package lib",
+			"rules/defs.bzl": "def f(): pass",
+		}},
+	}})
+
+	clean, err := WorkspaceContentHash(repo.Path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(clean, "git:"), clean)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Path, "user.bazelrc"), []byte("build --config=ci"), 0644))
+	ignoredRc, err := WorkspaceContentHash(repo.Path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(ignoredRc, "git:"), ignoredRc)
+	assert.NotEqual(t, clean, ignoredRc, "Expected ignored .bazelrc files to be part of the hash")
+	require.NoError(t, os.Remove(filepath.Join(repo.Path, "user.bazelrc")))
+
+	// Changes to sources only matter if files are added or removed
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Path, "lib", "lib.go"), []byte("package lib // changed"), 0644))
+	dirty, err := WorkspaceContentHash(repo.Path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dirty, "files:"), dirty)
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Path, "lib", "lib.go"), []byte("package lib // changed again"), 0644))
+	sameFiles, err := WorkspaceContentHash(repo.Path)
+	require.NoError(t, err)
+	assert.Equal(t, dirty, sameFiles)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Path, "rules", "defs.bzl"), []byte("def f(): return"), 0644))
+	bzlChanged, err := WorkspaceContentHash(repo.Path)
+	require.NoError(t, err)
+	assert.NotEqual(t, dirty, bzlChanged)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Path, "lib", "new.go"), []byte("package lib"), 0644))
+	fileAdded, err := WorkspaceContentHash(repo.Path)
+	require.NoError(t, err)
+	assert.NotEqual(t, bzlChanged, fileAdded)
+}
+
+func TestClientQueryCache(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "BUILD"), []byte("filegroup(name = 'a')"), 0644))
+	callLog := filepath.Join(t.TempDir(), "calls")
+	client := NewClient(workspace)
+	client.Binary = writeScript(t, `echo call >> "$CALL_LOG"
+case "$*" in
+  *partial*) echo //:partial; exit 3 ;;
+esac
+echo //:a`)
+	client.Env = []string{"CALL_LOG=" + callLog}
+	client.QueryCache = NewQueryCache(filepath.Join(t.TempDir(), "cache"), 0)
+	calls := func() int {
+		data, _ := os.ReadFile(callLog)
+		return strings.Count(string(data), "call")
+	}
+
+	first, err := client.RunQuery("query", "//...", []string{"--output=label"})
+	require.NoError(t, err)
+	assert.False(t, first.Cached)
+	second, err := client.RunQuery("query", "//...", []string{"--output=label"})
+	require.NoError(t, err)
+	assert.True(t, second.Cached)
+	assert.Equal(t, first.Stdout, second.Stdout)
+	assert.Equal(t, first.Args, second.Args)
+	assert.Equal(t, 1, calls())
+
+	_, err = client.RunQuery("query", "//...", []string{"--output=package"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls(), "flags are part of the key")
+
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "BUILD"), []byte("filegroup(name = 'b')"), 0644))
+	_, err = client.RunQuery("query", "//...", []string{"--output=label"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls(), "BUILD file changes invalidate the entries")
+
+	for i := 0; i < 2; i++ {
+		partial, err := client.RunQuery("query", "partial", []string{"--keep_going"})
+		require.NoError(t, err)
+		assert.True(t, partial.Partial)
+	}
+	assert.Equal(t, 5, calls(), "partial results are not cached")
+
+	copied := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(copied, "BUILD"), []byte("filegroup(name = 'b')"), 0644))
+	client.WorkspaceDir = copied
+	_, err = client.RunQuery("query", "//...", []string{"--output=label"})
+	require.NoError(t, err)
+	assert.Equal(t, 6, calls(), "the workspace directory is part of the key")
+}
+
+func TestWorkspaceContentHashNestedWorkspaces(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{Steps: []gitshelltest.Step{
+		gitshelltest.Commit{Message: "initial", Files: map[string]string{
+			"a/MODULE.bazel": "",
+			"a/lib/BUILD":    "go_library(name = 'a')",
+			"b/MODULE.bazel": "",
+			"b/lib/BUILD":    "go_library(name = 'b')",
+		}},
+		gitshelltest.Commit{Message: "change b", Files: map[string]string{"b/lib/BUILD": "go_library(name = 'b2')"}},
+	}})
+
+	a, err := WorkspaceContentHash(filepath.Join(repo.Path, "a"))
+	require.NoError(t, err)
+	b, err := WorkspaceContentHash(filepath.Join(repo.Path, "b"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(a, "git:"), a)
+	assert.NotEqual(t, a, b, "Expected the workspaces of one repository to be told apart")
+
+	_, err = gitshell.GitCheckout(repo.Path, "HEAD~1")
+	require.NoError(t, err)
+	previousA, err := WorkspaceContentHash(filepath.Join(repo.Path, "a"))
+	require.NoError(t, err)
+	assert.Equal(t, a, previousA, "Expected changes to the other workspace not to change the hash")
+}
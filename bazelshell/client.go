@@ -0,0 +1,278 @@
+package bazel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/open-ch/go-libs/logger"
+)
+
+// DefaultBinary is the Bazel executable used when none is configured
+const DefaultBinary = "bazel"
+
+// Client runs Bazel commands in a workspace
+type Client struct {
+	// WorkspaceDir is the directory commands are run in
+	WorkspaceDir string
+	// Binary is the executable to run, defaults to DefaultBinary.
+	// Use "bazelisk" to honour the .bazelversion of the workspace.
+	Binary string
+	// StartupOptions are passed before the command, i.e. --output_base=... or --bazelrc=...
+	StartupOptions []string
+	// Env is added to the environment of the current process, in the form KEY=value
+	Env []string
+	// Timeout limits the duration of each command, no limit if 0
+	Timeout time.Duration
+	// Logger receives the diagnostics of failed and partial commands, nothing is logged if nil
+	Logger logger.Logger
+	// QueryCache stores the results of queries, cqueries and aqueries, nothing is cached if nil.
+	// Partial results and failures are not cached.
+	QueryCache *QueryCache
+	// Cache configures the remote and disk caches of the commands executing actions,
+	// the flags of the .bazelrc files apply if nil
+	Cache *CacheOptions
+
+	ctx context.Context
+}
+
+// Result holds the outcome of a Bazel command
+type Result struct {
+	// Args is the full command line, the binary first
+	Args     []string
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// NewClient returns a client running the default binary in the given workspace
+func NewClient(workspaceDir string) *Client {
+	return &Client{WorkspaceDir: workspaceDir, Binary: DefaultBinary}
+}
+
+// WithContext returns a copy of the client running its commands with the given context.
+// Commands are killed when the context is done.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+// Context returns the context of the client, context.Background() if none was set
+func (c *Client) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// QueryResult is the raw output of a query, cquery or aquery
+type QueryResult struct {
+	Result
+	// Partial is true when errors were encountered with --keep_going (exit code 3):
+	// the output is incomplete and the diagnostics are in Stderr.
+	Partial bool
+	// Cached is true when the output comes from the QueryCache, Bazel was not run and Stderr is empty
+	Cached bool
+}
+
+// Run runs a Bazel command with the given arguments.
+// A non zero exit code is reported as an *ExitError together with the result holding the outputs.
+func (c *Client) Run(command string, args ...string) (*Result, error) {
+	ctx := c.Context()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	cmdLine := c.commandLine(command, args)
+	cmd := exec.CommandContext(ctx, cmdLine[0], cmdLine[1:]...)
+	cmd.Dir = c.WorkspaceDir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	result := &Result{Args: cmd.Args, Stdout: stdout.Bytes(), Stderr: stderr.Bytes(), ExitCode: -1}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	// A command which completed just as the context was done still succeeded
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return result, fmt.Errorf("bazel %s aborted: %w", command, ctxErr)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return result, &ExitError{
+			Command:  command,
+			Args:     result.Args,
+			ExitCode: ExitCode(result.ExitCode),
+			Stderr:   string(result.Stderr),
+			Err:      err,
+		}
+	}
+	if err != nil {
+		return result, fmt.Errorf("bazel %s failed: %w", command, err)
+	}
+	return result, nil
+}
+
+// commandLine returns the binary followed by the startup options, the command and its arguments
+func (c *Client) commandLine(command string, args []string) []string {
+	binary := c.Binary
+	if binary == "" {
+		binary = DefaultBinary
+	}
+	cmdLine := append([]string{binary}, c.StartupOptions...)
+	cmdLine = append(cmdLine, command)
+	if c.Cache != nil && cacheCommands[command] {
+		// Before the arguments so that flags given to the command take precedence
+		cmdLine = append(cmdLine, c.Cache.Flags()...)
+	}
+	return append(cmdLine, args...)
+}
+
+// Query performs a Bazel query and returns each line of the result
+func (c *Client) Query(query string, flags []string) ([]string, error) {
+	cmdOut, err := c.runQuery("query", query, flags)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(cmdOut), "\n"), nil
+}
+
+// QueryTargets is the Client equivalent of the package level QueryTargets
+func (c *Client) QueryTargets(query string, flags []string) ([]Target, error) {
+	cmdOut, err := c.runQuery("query", query, append([]string{"--output=streamed_jsonproto"}, flags...))
+	if err != nil {
+		return nil, err
+	}
+	return ParseStreamedJSONProto(bytes.NewReader(cmdOut))
+}
+
+// CQuery is the Client equivalent of the package level CQuery
+func (c *Client) CQuery(query string, flags []string) ([]ConfiguredTarget, error) {
+	cmdOut, err := c.runQuery("cquery", query, append([]string{"--output=jsonproto"}, flags...))
+	if err != nil {
+		return nil, err
+	}
+	return ParseCQueryJSONProto(bytes.NewReader(cmdOut))
+}
+
+// CQueryStarlark is the Client equivalent of the package level CQueryStarlark
+func (c *Client) CQueryStarlark(query, expr string, flags []string) ([]string, error) {
+	// Each value is JSON encoded on its own line, so that values spanning several lines are kept whole
+	encoded := "json.encode(str(" + expr + "))"
+	cmdOut, err := c.runQuery("cquery", query, append([]string{"--output=starlark", "--starlark:expr=" + encoded}, flags...))
+	if err != nil {
+		return nil, err
+	}
+	output := strings.TrimSuffix(string(cmdOut), "\n")
+	if output == "" {
+		return nil, nil
+	}
+	var values []string
+	for _, line := range strings.Split(output, "\n") {
+		var value string
+		if err := json.Unmarshal([]byte(line), &value); err != nil {
+			return nil, fmt.Errorf("error decoding cquery starlark output %q: %w", line, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// AQuery is the Client equivalent of the package level AQuery
+func (c *Client) AQuery(query string, flags []string) ([]Action, error) {
+	cmdOut, err := c.runQuery("aquery", query, append([]string{"--output=jsonproto"}, flags...))
+	if err != nil {
+		return nil, err
+	}
+	return ParseAQueryJSONProto(bytes.NewReader(cmdOut))
+}
+
+// Build builds the given target patterns
+func (c *Client) Build(targets []string, flags []string) (*Result, error) {
+	return c.Run("build", targetArgs(targets, flags)...)
+}
+
+// Test builds and runs the tests matching the given target patterns
+func (c *Client) Test(targets []string, flags []string) (*Result, error) {
+	return c.Run("test", targetArgs(targets, flags)...)
+}
+
+// targetArgs places the target patterns after --, so that negative patterns (-//foo/...) are not taken for flags
+func targetArgs(targets []string, flags []string) []string {
+	args := append(append([]string{}, flags...), "--")
+	return append(args, targets...)
+}
+
+// RunQuery performs a Bazel query, cquery or aquery and returns its raw output.
+// A partial result (exit code 3 with --keep_going) is not an error, check QueryResult.Partial.
+func (c *Client) RunQuery(command, query string, flags []string) (*QueryResult, error) {
+	args := append(append([]string{}, flags...), query)
+	var cacheKey string
+	if c.QueryCache != nil {
+		var err error
+		if cacheKey, err = c.queryCacheKey(append([]string{command}, args...)); err != nil {
+			c.logger().Debugf("bazel %s not cached: %v", command, err)
+		} else if stdout, ok := c.QueryCache.Get(cacheKey); ok {
+			c.logger().Debugf("bazel %s served from cache: %s", command, query)
+			return &QueryResult{Result: Result{Args: c.commandLine(command, args), Stdout: stdout}, Cached: true}, nil
+		}
+	}
+	result, err := c.Run(command, args...)
+	queryResult := &QueryResult{Result: *result}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode == ExitPartialAnalysisFailure {
+		// Errors were encountered in the input BUILD files, the output is most likely incomplete due to --keep_going
+		queryResult.Partial = true
+		c.logger().Warnf("bazel %s returned partial results: %s", command, strings.Join(exitErr.Errors(), "; "))
+		return queryResult, nil
+	}
+	if err != nil {
+		c.logger().Debugf("bazel %s failed: %v, command: %v, stderr: %s", command, err, result.Args, result.Stderr)
+		return queryResult, err
+	}
+	if cacheKey != "" {
+		if err := c.QueryCache.Put(cacheKey, result.Stdout); err != nil {
+			c.logger().Debugf("bazel %s not cached: %v", command, err)
+		}
+	}
+	return queryResult, nil
+}
+
+// runQuery performs a Bazel query, cquery or aquery and returns its stdout, partial results included
+func (c *Client) runQuery(command, query string, flags []string) ([]byte, error) {
+	result, err := c.RunQuery(command, query, flags)
+	if err != nil {
+		return nil, err
+	}
+	return result.Stdout, nil
+}
+
+func (c *Client) logger() logger.Logger {
+	if c.Logger == nil {
+		return nopLogger{}
+	}
+	return c.Logger
+}
+
+// nopLogger discards everything, the default of Client.Logger
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Warnf(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+func (nopLogger) Fatalf(string, ...interface{}) {}
+func (nopLogger) Panicf(string, ...interface{}) {}
@@ -0,0 +1,186 @@
+package bazel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeScript writes an executable shell script standing in for bazel and returns its path
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "bazel")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755))
+	return path
+}
+
+func TestClientRun(t *testing.T) {
+	client := NewClient(t.TempDir())
+	client.Binary = writeScript(t, `printf '%s\n' "$@"; echo "$FAKE_VALUE" >&2; exit 1`)
+	client.StartupOptions = []string{"--output_base=/tmp/base"}
+	client.Env = []string{"FAKE_VALUE=from env"}
+
+	result, err := client.Run("build", "--keep_going", "//...")
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.ExitCode)
+	assert.Equal(t, "--output_base=/tmp/base\nbuild\n--keep_going\n//...\n", string(result.Stdout))
+	assert.Equal(t, "from env\n", string(result.Stderr))
+	assert.Equal(t, []string{client.Binary, "--output_base=/tmp/base", "build", "--keep_going", "//..."}, result.Args)
+}
+
+func TestClientRunContext(t *testing.T) {
+	client := NewClient(t.TempDir())
+	client.Binary = writeScript(t, "exec sleep 5")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.WithContext(ctx).Run("build")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, context.Background(), client.Context(), "Expected WithContext to leave the original client untouched")
+
+	client.Timeout = 10 * time.Millisecond
+	start := time.Now()
+	_, err = client.Run("build")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// doneAfterRunContext reports being done without ever cancelling, like a deadline hit right after the command exited
+type doneAfterRunContext struct {
+	context.Context
+}
+
+func (doneAfterRunContext) Err() error {
+	return context.DeadlineExceeded
+}
+
+func TestClientRunCompletedBeforeContextDone(t *testing.T) {
+	client := NewClient(t.TempDir())
+	client.Binary = writeScript(t, "echo done")
+
+	result, err := client.WithContext(doneAfterRunContext{context.Background()}).Run("build")
+	require.NoError(t, err, "Expected a command which completed not to be reported as aborted")
+	assert.Equal(t, "done\n", string(result.Stdout))
+}
+
+func TestClientQueryAndBuildArgs(t *testing.T) {
+	client := NewClient(t.TempDir())
+	client.Binary = writeScript(t, `printf '%s\n' "$@"`)
+
+	lines, err := client.Query("deps(//foo)", []string{"--noimplicit_deps"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"query", "--noimplicit_deps", "deps(//foo)", ""}, lines)
+
+	result, err := client.Test([]string{"//...", "-//slow/..."}, []string{"--test_output=errors"})
+	assert.NoError(t, err)
+	assert.Equal(t, "test\n--test_output=errors\n--\n//...\n-//slow/...\n", string(result.Stdout))
+}
+
+func TestClientMissingBinary(t *testing.T) {
+	client := NewClient(t.TempDir())
+	client.Binary = filepath.Join(t.TempDir(), "does-not-exist")
+
+	result, err := client.Run("version")
+	assert.Error(t, err)
+	assert.Equal(t, -1, result.ExitCode)
+	assert.True(t, strings.HasSuffix(result.Args[0], "does-not-exist"))
+}
+
+// recordingLogger keeps the formatted messages by level
+type recordingLogger struct {
+	messages map[string][]string
+}
+
+func (l *recordingLogger) record(level, format string, args ...interface{}) {
+	if l.messages == nil {
+		l.messages = map[string][]string{}
+	}
+	l.messages[level] = append(l.messages[level], fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.record("debug", format, args...)
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.record("info", format, args...)
+}
+
+func (l *recordingLogger) Warnf(format string, args ...interface{}) {
+	l.record("warn", format, args...)
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.record("error", format, args...)
+}
+
+func (l *recordingLogger) Fatalf(format string, args ...interface{}) {
+	l.record("fatal", format, args...)
+}
+
+func (l *recordingLogger) Panicf(format string, args ...interface{}) {
+	l.record("panic", format, args...)
+}
+
+func TestClientExitError(t *testing.T) {
+	client := NewClient(t.TempDir())
+	client.Binary = writeScript(t, `echo "INFO: Analyzed 3 targets" >&2; echo "ERROR: //foo:bar: missing input file" >&2; exit 1`)
+
+	_, err := client.Build([]string{"//foo:bar"}, nil)
+	var exitErr *ExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, ExitBuildFailure, exitErr.ExitCode)
+	assert.Equal(t, []string{"//foo:bar: missing input file"}, exitErr.Errors())
+	assert.EqualError(t, err, "bazel build failed with exit code 1 (build failure): //foo:bar: missing input file")
+	assert.False(t, exitErr.ExitCode.Retryable())
+}
+
+func TestClientPartialQuery(t *testing.T) {
+	log := &recordingLogger{}
+	client := NewClient(t.TempDir())
+	client.Logger = log
+	client.Binary = writeScript(t, `echo //foo:a; echo "ERROR: no such package 'broken'" >&2; exit 3`)
+
+	result, err := client.RunQuery("query", "//...", []string{"--keep_going"})
+	assert.NoError(t, err)
+	assert.True(t, result.Partial)
+	assert.Equal(t, "//foo:a\n", string(result.Stdout))
+	assert.Contains(t, string(result.Stderr), "no such package")
+	assert.Equal(t, []string{"bazel query returned partial results: no such package 'broken'"}, log.messages["warn"])
+
+	lines, err := client.Query("//...", []string{"--keep_going"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"//foo:a", ""}, lines)
+}
+
+func TestClientFailedQuery(t *testing.T) {
+	log := &recordingLogger{}
+	client := NewClient(t.TempDir())
+	client.Logger = log
+	client.Binary = writeScript(t, `echo "ERROR: Skipping 'nope': no such target" >&2; exit 7`)
+
+	_, err := client.Query("nope", nil)
+	var exitErr *ExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, ExitAnalysisFailure, exitErr.ExitCode)
+	assert.Len(t, log.messages["debug"], 1)
+	assert.Empty(t, log.messages["warn"])
+}
+
+func TestExitCodeString(t *testing.T) {
+	assert.Equal(t, "out of memory", ExitOOM.String())
+	assert.Equal(t, "unknown exit code 99", ExitCode(99).String())
+	assert.True(t, ExitInternalError.Retryable())
+}
@@ -0,0 +1,303 @@
+package bazel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Configuration is a build configuration as reported by cquery and aquery
+type Configuration struct {
+	ID           int    `json:"id"`
+	Checksum     string `json:"checksum"`
+	Mnemonic     string `json:"mnemonic"`
+	PlatformName string `json:"platformName"`
+	CPU          string `json:"cpu"`
+	// IsTool is true for the execution configuration of tools
+	IsTool bool `json:"isTool"`
+}
+
+// ConfiguredTarget is a target together with the configuration it was analyzed in
+type ConfiguredTarget struct {
+	Target Target
+	// Configuration is nil for targets without configuration, i.e. source files
+	Configuration *Configuration
+}
+
+// CQuery performs a Bazel cquery and returns the configured targets, select() being resolved
+// for the configuration set by the flags (i.e. --platforms).
+// see https://bazel.build/query/cquery for more details
+func CQuery(folder, query string, flags []string) ([]ConfiguredTarget, error) {
+	return NewClient(folder).CQuery(query, flags)
+}
+
+// CQueryStarlark performs a Bazel cquery and returns the result of the Starlark expression
+// evaluated for each configured target, one entry per target, converted with str().
+// Values may span several lines: each one is JSON encoded by Bazel, which needs the json module
+// in cquery Starlark (Bazel 6 and later).
+// see https://bazel.build/query/cquery#output-format-definition for more details
+func CQueryStarlark(folder, query, expr string, flags []string) ([]string, error) {
+	return NewClient(folder).CQueryStarlark(query, expr, flags)
+}
+
+type jsonCQueryResult struct {
+	Results []struct {
+		Target          jsonTarget `json:"target"`
+		ConfigurationID int        `json:"configurationId"`
+	} `json:"results"`
+	Configurations []Configuration `json:"configurations"`
+}
+
+// ParseCQueryJSONProto parses the output of a cquery with --output=jsonproto
+func ParseCQueryJSONProto(r io.Reader) ([]ConfiguredTarget, error) {
+	var raw jsonCQueryResult
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error decoding cquery output: %w", err)
+	}
+	configurations := make(map[int]*Configuration, len(raw.Configurations))
+	for i := range raw.Configurations {
+		configurations[raw.Configurations[i].ID] = &raw.Configurations[i]
+	}
+	targets := make([]ConfiguredTarget, 0, len(raw.Results))
+	for _, result := range raw.Results {
+		target, err := result.Target.toTarget()
+		if err != nil {
+			return nil, err
+		}
+		// Configuration ids start at 1, 0 means no configuration
+		targets = append(targets, ConfiguredTarget{Target: target, Configuration: configurations[result.ConfigurationID]})
+	}
+	return targets, nil
+}
+
+// Action is a single action of the action graph, with its inputs and outputs expanded to exec paths
+type Action struct {
+	// Label is the target that registered the action
+	Label string
+	// RuleClass is the kind of the target, i.e. go_library
+	RuleClass     string
+	Mnemonic      string
+	Configuration *Configuration
+	// Arguments is the command line, the executable first
+	Arguments         []string
+	Environment       map[string]string
+	Inputs            []string
+	Outputs           []string
+	PrimaryOutput     string
+	ExecutionPlatform string
+}
+
+// AQuery performs a Bazel aquery and returns the actions of the matching targets.
+// see https://bazel.build/query/aquery for more details
+func AQuery(folder, query string, flags []string) ([]Action, error) {
+	return NewClient(folder).AQuery(query, flags)
+}
+
+type jsonActionGraph struct {
+	Artifacts []struct {
+		ID             int `json:"id"`
+		PathFragmentID int `json:"pathFragmentId"`
+	} `json:"artifacts"`
+	Actions []struct {
+		TargetID             int        `json:"targetId"`
+		Mnemonic             string     `json:"mnemonic"`
+		ConfigurationID      int        `json:"configurationId"`
+		Arguments            []string   `json:"arguments"`
+		EnvironmentVariables []KeyValue `json:"environmentVariables"`
+		InputDepSetIDs       []int      `json:"inputDepSetIds"`
+		OutputIDs            []int      `json:"outputIds"`
+		PrimaryOutputID      int        `json:"primaryOutputId"`
+		ExecutionPlatform    string     `json:"executionPlatform"`
+	} `json:"actions"`
+	Targets []struct {
+		ID          int    `json:"id"`
+		Label       string `json:"label"`
+		RuleClassID int    `json:"ruleClassId"`
+	} `json:"targets"`
+	DepSetOfFiles []struct {
+		ID                  int   `json:"id"`
+		DirectArtifactIDs   []int `json:"directArtifactIds"`
+		TransitiveDepSetIDs []int `json:"transitiveDepSetIds"`
+	} `json:"depSetOfFiles"`
+	Configuration []Configuration `json:"configuration"`
+	RuleClasses   []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"ruleClasses"`
+	PathFragments []struct {
+		ID       int    `json:"id"`
+		Label    string `json:"label"`
+		ParentID int    `json:"parentId"`
+	} `json:"pathFragments"`
+}
+
+// actionGraph resolves the ids of an aquery output
+type actionGraph struct {
+	raw           *jsonActionGraph
+	fragments     map[int]int
+	paths         map[int]string
+	artifacts     map[int]int
+	depSets       map[int]int
+	expandedSets  map[int][]int
+	configuration map[int]*Configuration
+}
+
+// ParseAQueryJSONProto parses the output of an aquery with --output=jsonproto
+func ParseAQueryJSONProto(r io.Reader) ([]Action, error) {
+	var raw jsonActionGraph
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error decoding aquery output: %w", err)
+	}
+	g := &actionGraph{
+		raw:           &raw,
+		fragments:     map[int]int{},
+		paths:         map[int]string{},
+		artifacts:     map[int]int{},
+		depSets:       map[int]int{},
+		expandedSets:  map[int][]int{},
+		configuration: map[int]*Configuration{},
+	}
+	for i, fragment := range raw.PathFragments {
+		g.fragments[fragment.ID] = i
+	}
+	for _, artifact := range raw.Artifacts {
+		g.artifacts[artifact.ID] = artifact.PathFragmentID
+	}
+	for i, depSet := range raw.DepSetOfFiles {
+		g.depSets[depSet.ID] = i
+	}
+	for i := range raw.Configuration {
+		g.configuration[raw.Configuration[i].ID] = &raw.Configuration[i]
+	}
+	targets := map[int]int{}
+	for i, target := range raw.Targets {
+		targets[target.ID] = i
+	}
+	ruleClasses := map[int]string{}
+	for _, ruleClass := range raw.RuleClasses {
+		ruleClasses[ruleClass.ID] = ruleClass.Name
+	}
+
+	actions := make([]Action, 0, len(raw.Actions))
+	for _, rawAction := range raw.Actions {
+		action := Action{
+			Mnemonic:          rawAction.Mnemonic,
+			Configuration:     g.configuration[rawAction.ConfigurationID],
+			Arguments:         rawAction.Arguments,
+			ExecutionPlatform: rawAction.ExecutionPlatform,
+		}
+		if i, ok := targets[rawAction.TargetID]; ok {
+			action.Label = raw.Targets[i].Label
+			action.RuleClass = ruleClasses[raw.Targets[i].RuleClassID]
+		}
+		if len(rawAction.EnvironmentVariables) > 0 {
+			action.Environment = make(map[string]string, len(rawAction.EnvironmentVariables))
+			for _, kv := range rawAction.EnvironmentVariables {
+				action.Environment[kv.Key] = kv.Value
+			}
+		}
+		seen := map[int]bool{}
+		for _, depSetID := range rawAction.InputDepSetIDs {
+			artifactIDs, err := g.expandDepSet(depSetID, map[int]bool{})
+			if err != nil {
+				return nil, err
+			}
+			for _, artifactID := range artifactIDs {
+				if seen[artifactID] {
+					continue
+				}
+				seen[artifactID] = true
+				path, err := g.artifactPath(artifactID)
+				if err != nil {
+					return nil, err
+				}
+				action.Inputs = append(action.Inputs, path)
+			}
+		}
+		for _, artifactID := range rawAction.OutputIDs {
+			path, err := g.artifactPath(artifactID)
+			if err != nil {
+				return nil, err
+			}
+			action.Outputs = append(action.Outputs, path)
+		}
+		if rawAction.PrimaryOutputID != 0 {
+			path, err := g.artifactPath(rawAction.PrimaryOutputID)
+			if err != nil {
+				return nil, err
+			}
+			action.PrimaryOutput = path
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// expandDepSet returns the artifact ids of a dep set and its transitive dep sets, without duplicates
+func (g *actionGraph) expandDepSet(id int, visiting map[int]bool) ([]int, error) {
+	if expanded, ok := g.expandedSets[id]; ok {
+		return expanded, nil
+	}
+	i, ok := g.depSets[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown dep set id %d in aquery output", id)
+	}
+	if visiting[id] {
+		return nil, fmt.Errorf("cycle through dep set id %d in aquery output", id)
+	}
+	visiting[id] = true
+	depSet := g.raw.DepSetOfFiles[i]
+	var expanded []int
+	seen := map[int]bool{}
+	add := func(ids []int) {
+		for _, artifactID := range ids {
+			if !seen[artifactID] {
+				seen[artifactID] = true
+				expanded = append(expanded, artifactID)
+			}
+		}
+	}
+	add(depSet.DirectArtifactIDs)
+	for _, transitiveID := range depSet.TransitiveDepSetIDs {
+		transitive, err := g.expandDepSet(transitiveID, visiting)
+		if err != nil {
+			return nil, err
+		}
+		add(transitive)
+	}
+	g.expandedSets[id] = expanded
+	return expanded, nil
+}
+
+func (g *actionGraph) artifactPath(id int) (string, error) {
+	fragmentID, ok := g.artifacts[id]
+	if !ok {
+		return "", fmt.Errorf("unknown artifact id %d in aquery output", id)
+	}
+	return g.fragmentPath(fragmentID, 0)
+}
+
+// fragmentPath joins the labels of a path fragment and its parents
+func (g *actionGraph) fragmentPath(id, depth int) (string, error) {
+	if path, ok := g.paths[id]; ok {
+		return path, nil
+	}
+	i, ok := g.fragments[id]
+	if !ok {
+		return "", fmt.Errorf("unknown path fragment id %d in aquery output", id)
+	}
+	if depth > len(g.fragments) {
+		return "", fmt.Errorf("cycle through path fragment id %d in aquery output", id)
+	}
+	fragment := g.raw.PathFragments[i]
+	path := fragment.Label
+	if fragment.ParentID != 0 {
+		parent, err := g.fragmentPath(fragment.ParentID, depth+1)
+		if err != nil {
+			return "", err
+		}
+		path = parent + "/" + path
+	}
+	g.paths[id] = path
+	return path, nil
+}
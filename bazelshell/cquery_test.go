@@ -0,0 +1,77 @@
+package bazel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/bazelshell/bazeltest"
+)
+
+func TestParseCQueryJSONProto(t *testing.T) {
+	targets, err := ParseCQueryJSONProto(openTestFile(t, "cquery.jsonproto"))
+	require.NoError(t, err)
+	require.Len(t, targets, 3)
+
+	binary := targets[0]
+	assert.Equal(t, "//cmd/tool:tool", binary.Target.Label)
+	assert.Equal(t, "go_binary", binary.Target.Kind)
+	goos, _ := binary.Target.Attr("goos")
+	assert.Equal(t, "linux", goos.StringValue)
+	assert.Equal(t, &Configuration{ID: 1, Checksum: "a3b5c7d9", Mnemonic: "k8-fastbuild", PlatformName: "k8", CPU: "k8"}, binary.Configuration)
+
+	assert.Nil(t, targets[1].Configuration, "Expected source files to have no configuration")
+	assert.True(t, targets[2].Configuration.IsTool)
+
+	_, err = ParseCQueryJSONProto(strings.NewReader("not json"))
+	assert.Error(t, err)
+}
+
+func TestParseAQueryJSONProto(t *testing.T) {
+	actions, err := ParseAQueryJSONProto(openTestFile(t, "aquery.jsonproto"))
+	require.NoError(t, err)
+	require.Len(t, actions, 2)
+
+	compile := actions[0]
+	assert.Equal(t, "//cmd/tool:tool_lib", compile.Label)
+	assert.Equal(t, "go_library", compile.RuleClass)
+	assert.Equal(t, "GoCompilePkg", compile.Mnemonic)
+	assert.Equal(t, "k8-fastbuild", compile.Configuration.Mnemonic)
+	assert.Equal(t, "compilepkg", compile.Arguments[1])
+	assert.Equal(t, map[string]string{"GOARCH": "amd64", "GOOS": "linux"}, compile.Environment)
+	assert.Equal(t, []string{"cmd/tool/main.go", "cmd/tool/flags.go"}, compile.Inputs, "Expected inputs to be expanded without duplicates")
+	assert.Equal(t, []string{"bazel-out/k8-fastbuild/bin/cmd/tool/tool_lib.a"}, compile.Outputs)
+	assert.Equal(t, "bazel-out/k8-fastbuild/bin/cmd/tool/tool_lib.a", compile.PrimaryOutput)
+	assert.Equal(t, "@local_config_platform//:host", compile.ExecutionPlatform)
+
+	link := actions[1]
+	assert.Equal(t, "go_binary", link.RuleClass)
+	assert.Nil(t, link.Environment)
+	assert.Equal(t, []string{"bazel-out/k8-fastbuild/bin/cmd/tool/tool_lib.a", "cmd/tool/main.go", "cmd/tool/flags.go"}, link.Inputs)
+}
+
+func TestParseAQueryJSONProtoErrors(t *testing.T) {
+	_, err := ParseAQueryJSONProto(strings.NewReader(`{"actions":[{"inputDepSetIds":[1]}]}`))
+	assert.Error(t, err, "Expected unknown dep sets to be reported")
+
+	_, err = ParseAQueryJSONProto(strings.NewReader(`{"actions":[{"inputDepSetIds":[1]}],"depSetOfFiles":[{"id":1,"transitiveDepSetIds":[1]}]}`))
+	assert.Error(t, err, "Expected dep set cycles to be reported")
+
+	_, err = ParseAQueryJSONProto(strings.NewReader(`{"actions":[{"outputIds":[1]}],"artifacts":[{"id":1,"pathFragmentId":1}],"pathFragments":[{"id":1,"label":"a","parentId":2},{"id":2,"label":"b","parentId":1}]}`))
+	assert.Error(t, err, "Expected path fragment cycles to be reported")
+}
+
+func TestClientCQueryStarlark(t *testing.T) {
+	fake := bazeltest.New(t, bazeltest.Rule{
+		Pattern: `^cquery --output=starlark --starlark:expr=json\.encode\(str\("\\n"\.join\(target\.files\)\)\) //lib$`,
+		Stdout:  "\"lib.a\\nlib.x\"\n\"\"\n",
+	})
+	client := NewClient(t.TempDir())
+	client.Binary = fake.Path
+
+	values, err := client.CQueryStarlark("//lib", `"\n".join(target.files)`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lib.a\nlib.x", ""}, values, "Expected values spanning several lines to be kept whole")
+}
@@ -0,0 +1,131 @@
+package bazel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExitCode is the exit code of a Bazel command
+// see https://bazel.build/run/scripts#exit-codes for more details
+type ExitCode int
+
+const (
+	// ExitSuccess means the command succeeded
+	ExitSuccess ExitCode = 0
+	// ExitBuildFailure means the build failed, also used for BUILD file parsing failures
+	ExitBuildFailure ExitCode = 1
+	// ExitCommandLineError means bad or illegal flags or command combination, or bad environment variables
+	ExitCommandLineError ExitCode = 2
+	// ExitPartialAnalysisFailure means some targets or query inputs failed with --keep_going,
+	// for bazel test it means the build succeeded but some tests failed or timed out
+	ExitPartialAnalysisFailure ExitCode = 3
+	// ExitTestsFailed is the meaning of exit code 3 for bazel test
+	ExitTestsFailed ExitCode = 3
+	// ExitNoTestsFound means the build succeeded but no tests were found although testing was requested
+	ExitNoTestsFound ExitCode = 4
+	// ExitRunFailure means bazel run could not run the binary
+	ExitRunFailure ExitCode = 6
+	// ExitAnalysisFailure means the analysis or the query failed
+	ExitAnalysisFailure ExitCode = 7
+	// ExitInterrupted means the command was interrupted and shut down in an orderly way
+	ExitInterrupted ExitCode = 8
+	// ExitLockHeld means the server lock is held and --noblock_for_lock was passed
+	ExitLockHeld ExitCode = 9
+	// ExitRemoteEnvironmentalError means an environmental issue with remote execution or caching
+	ExitRemoteEnvironmentalError ExitCode = 32
+	// ExitOOM means the Bazel server ran out of memory
+	ExitOOM ExitCode = 33
+	// ExitRemoteError means a remote execution or caching error
+	ExitRemoteError ExitCode = 34
+	// ExitLocalEnvironmentalError means a local environmental issue, suspected permanent
+	ExitLocalEnvironmentalError ExitCode = 36
+	// ExitInternalError means an unhandled exception in the Bazel server, i.e. a crash
+	ExitInternalError ExitCode = 37
+	// ExitBESTransientError means a transient error publishing results to the Build Event Service
+	ExitBESTransientError ExitCode = 38
+	// ExitRemoteCacheEvicted means blobs required by Bazel were evicted from the remote cache
+	ExitRemoteCacheEvicted ExitCode = 39
+	// ExitBESPersistentError means a persistent error publishing results to the Build Event Service
+	ExitBESPersistentError ExitCode = 45
+	// ExitExternalDepsError means fetching external dependencies failed
+	ExitExternalDepsError ExitCode = 48
+)
+
+var exitCodeDescriptions = map[ExitCode]string{
+	ExitSuccess:                  "success",
+	ExitBuildFailure:             "build failure",
+	ExitCommandLineError:         "command line error",
+	ExitPartialAnalysisFailure:   "partial failure or tests failed",
+	ExitNoTestsFound:             "no tests found",
+	ExitRunFailure:               "run failure",
+	ExitAnalysisFailure:          "analysis failure",
+	ExitInterrupted:              "interrupted",
+	ExitLockHeld:                 "server lock held",
+	ExitRemoteEnvironmentalError: "remote environmental error",
+	ExitOOM:                      "out of memory",
+	ExitRemoteError:              "remote error",
+	ExitLocalEnvironmentalError:  "local environmental error",
+	ExitInternalError:            "internal error",
+	ExitBESTransientError:        "transient build event service error",
+	ExitRemoteCacheEvicted:       "remote cache evicted",
+	ExitBESPersistentError:       "persistent build event service error",
+	ExitExternalDepsError:        "external dependencies error",
+}
+
+// String returns a short description of the exit code
+func (c ExitCode) String() string {
+	if description, ok := exitCodeDescriptions[c]; ok {
+		return description
+	}
+	return fmt.Sprintf("unknown exit code %d", int(c))
+}
+
+// Retryable returns true for exit codes caused by the environment rather than the workspace,
+// running the same command again may succeed.
+func (c ExitCode) Retryable() bool {
+	switch c {
+	case ExitInterrupted, ExitLockHeld, ExitRemoteEnvironmentalError, ExitOOM, ExitRemoteError,
+		ExitInternalError, ExitBESTransientError, ExitRemoteCacheEvicted:
+		return true
+	}
+	return false
+}
+
+// ExitError is returned when a Bazel command exits with a non zero exit code
+type ExitError struct {
+	Command  string
+	Args     []string
+	ExitCode ExitCode
+	// Stderr holds the diagnostics of Bazel
+	Stderr string
+	// Err is the underlying *exec.ExitError
+	Err error
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("bazel %s failed with exit code %d (%s)", e.Command, int(e.ExitCode), e.ExitCode)
+	if errors := e.Errors(); len(errors) > 0 {
+		msg += ": " + errors[0]
+	}
+	return msg
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns the messages of the ERROR: lines printed by Bazel on stderr
+func (e *ExitError) Errors() []string {
+	return diagnostics(e.Stderr, "ERROR: ")
+}
+
+// diagnostics returns the messages of the stderr lines with the given prefix, i.e. "ERROR: " or "WARNING: "
+func diagnostics(stderr, prefix string) []string {
+	var messages []string
+	for _, line := range strings.Split(stderr, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			messages = append(messages, strings.TrimPrefix(line, prefix))
+		}
+	}
+	return messages
+}
@@ -0,0 +1,483 @@
+package bazel
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Graph is a dependency graph of targets, edges go from a target to its dependencies
+type Graph struct {
+	deps  map[string]map[string]bool
+	rdeps map[string]map[string]bool
+	kinds map[string]string
+}
+
+// Edge is a dependency of From on To
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// NewGraph returns an empty graph
+func NewGraph() *Graph {
+	return &Graph{deps: map[string]map[string]bool{}, rdeps: map[string]map[string]bool{}, kinds: map[string]string{}}
+}
+
+// AddNode adds a target to the graph, it is a no-op if it is already in it
+func (g *Graph) AddNode(label string) {
+	if _, ok := g.deps[label]; !ok {
+		g.deps[label] = map[string]bool{}
+		g.rdeps[label] = map[string]bool{}
+	}
+}
+
+// AddEdge adds a dependency of from on to, adding the targets as needed
+func (g *Graph) AddEdge(from, to string) {
+	g.AddNode(from)
+	g.AddNode(to)
+	g.deps[from][to] = true
+	g.rdeps[to][from] = true
+}
+
+// SetKind sets the kind of a target, i.e. go_library or "source file", adding it as needed
+func (g *Graph) SetKind(label, kind string) {
+	g.AddNode(label)
+	g.kinds[label] = kind
+}
+
+// Kind returns the kind of a target, empty if unknown
+func (g *Graph) Kind(label string) string {
+	return g.kinds[label]
+}
+
+// HasNode returns true if the target is in the graph
+func (g *Graph) HasNode(label string) bool {
+	_, ok := g.deps[label]
+	return ok
+}
+
+// Nodes returns the targets of the graph, sorted
+func (g *Graph) Nodes() []string {
+	nodes := make([]string, 0, len(g.deps))
+	for label := range g.deps {
+		nodes = append(nodes, label)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Edges returns the dependencies of the graph, sorted
+func (g *Graph) Edges() []Edge {
+	var edges []Edge
+	for _, from := range g.Nodes() {
+		for _, to := range g.Deps(from) {
+			edges = append(edges, Edge{From: from, To: to})
+		}
+	}
+	return edges
+}
+
+// Deps returns the direct dependencies of a target, sorted
+func (g *Graph) Deps(label string) []string {
+	return sortedSet(g.deps[label])
+}
+
+// RDeps returns the targets depending directly on a target, sorted
+func (g *Graph) RDeps(label string) []string {
+	return sortedSet(g.rdeps[label])
+}
+
+func sortedSet(set map[string]bool) []string {
+	values := make([]string, 0, len(set))
+	for value := range set {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// Reachable returns the targets the given ones depend on transitively, themselves included, sorted.
+// Targets which are not in the graph are ignored.
+func (g *Graph) Reachable(labels ...string) []string {
+	visited := map[string]bool{}
+	stack := append([]string{}, labels...)
+	for len(stack) > 0 {
+		label := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[label] || !g.HasNode(label) {
+			continue
+		}
+		visited[label] = true
+		for dep := range g.deps[label] {
+			stack = append(stack, dep)
+		}
+	}
+	return sortedSet(visited)
+}
+
+// ShortestPath returns a shortest dependency path from one target to another, both included,
+// nil if to is not reachable from from. Among paths of the same length, the first in label order is returned.
+func (g *Graph) ShortestPath(from, to string) []string {
+	if !g.HasNode(from) || !g.HasNode(to) {
+		return nil
+	}
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		label := queue[0]
+		queue = queue[1:]
+		if label == to {
+			path := []string{to}
+			for label != from {
+				label = previous[label]
+				path = append([]string{label}, path...)
+			}
+			return path
+		}
+		for _, dep := range g.Deps(label) {
+			if _, seen := previous[dep]; !seen {
+				previous[dep] = label
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// CycleError is returned when a graph has cycles where none are allowed
+type CycleError struct {
+	Cycles [][]string
+}
+
+func (e *CycleError) Error() string {
+	cycles := make([]string, 0, len(e.Cycles))
+	for _, cycle := range e.Cycles {
+		cycles = append(cycles, "["+strings.Join(cycle, ", ")+"]")
+	}
+	return fmt.Sprintf("dependency graph has %d cycle(s): %s", len(e.Cycles), strings.Join(cycles, ", "))
+}
+
+// TopologicalOrder returns the targets ordered so that every target comes after its dependencies,
+// in label order where the dependencies allow it. It returns a *CycleError if the graph has cycles.
+func (g *Graph) TopologicalOrder() ([]string, error) {
+	if cycles := g.Cycles(); len(cycles) > 0 {
+		return nil, &CycleError{Cycles: cycles}
+	}
+	remaining := map[string]int{}
+	ready := &labelHeap{}
+	for label, deps := range g.deps {
+		remaining[label] = len(deps)
+		if len(deps) == 0 {
+			heap.Push(ready, label)
+		}
+	}
+	order := make([]string, 0, len(g.deps))
+	for ready.Len() > 0 {
+		label := heap.Pop(ready).(string)
+		order = append(order, label)
+		for rdep := range g.rdeps[label] {
+			remaining[rdep]--
+			if remaining[rdep] == 0 {
+				heap.Push(ready, rdep)
+			}
+		}
+	}
+	return order, nil
+}
+
+// labelHeap is a min-heap of labels, see container/heap
+type labelHeap []string
+
+func (h labelHeap) Len() int           { return len(h) }
+func (h labelHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h labelHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *labelHeap) Push(x interface{}) {
+	*h = append(*h, x.(string))
+}
+
+func (h *labelHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Cycles returns the cycles of the graph: the strongly connected components of more than one target
+// and the targets depending on themselves. Each cycle is sorted, and the cycles by their first target.
+func (g *Graph) Cycles() [][]string {
+	// Tarjan's algorithm, iterative so that deep graphs do not exhaust the stack
+	index := map[string]int{}
+	lowLink := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var cycles [][]string
+	type frame struct {
+		label string
+		deps  []string
+		next  int
+	}
+	for _, root := range g.Nodes() {
+		if _, visited := index[root]; visited {
+			continue
+		}
+		frames := []*frame{{label: root, deps: g.Deps(root)}}
+		index[root], lowLink[root] = len(index), len(index)
+		stack = append(stack, root)
+		onStack[root] = true
+		for len(frames) > 0 {
+			f := frames[len(frames)-1]
+			if f.next < len(f.deps) {
+				dep := f.deps[f.next]
+				f.next++
+				if _, visited := index[dep]; !visited {
+					index[dep], lowLink[dep] = len(index), len(index)
+					stack = append(stack, dep)
+					onStack[dep] = true
+					frames = append(frames, &frame{label: dep, deps: g.Deps(dep)})
+				} else if onStack[dep] && index[dep] < lowLink[f.label] {
+					lowLink[f.label] = index[dep]
+				}
+				continue
+			}
+			frames = frames[:len(frames)-1]
+			if len(frames) > 0 {
+				parent := frames[len(frames)-1].label
+				if lowLink[f.label] < lowLink[parent] {
+					lowLink[parent] = lowLink[f.label]
+				}
+			}
+			if lowLink[f.label] != index[f.label] {
+				continue
+			}
+			var component []string
+			for {
+				label := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[label] = false
+				component = append(component, label)
+				if label == f.label {
+					break
+				}
+			}
+			if len(component) > 1 || g.deps[f.label][f.label] {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles
+}
+
+// GraphFromTargets returns the graph of the targets of a query with their kinds.
+// Like --output=graph, only the dependencies on targets of the result are edges.
+func GraphFromTargets(targets []Target) *Graph {
+	g := NewGraph()
+	for _, target := range targets {
+		g.SetKind(target.Label, target.Kind)
+	}
+	for _, target := range targets {
+		for _, input := range target.RuleInputs {
+			if g.HasNode(input) {
+				g.AddEdge(target.Label, input)
+			}
+		}
+	}
+	return g
+}
+
+// QueryGraph performs a Bazel query with --output=graph and returns the dependency graph of the result
+func (c *Client) QueryGraph(query string, flags []string) (*Graph, error) {
+	cmdOut, err := c.runQuery("query", query, append([]string{"--output=graph"}, flags...))
+	if err != nil {
+		return nil, err
+	}
+	return ParseGraphOutput(bytes.NewReader(cmdOut))
+}
+
+// ParseGraphOutput parses the output of a query with --output=graph.
+// Factored nodes (the default, disabled with --nograph:factored) are expanded to one node per label.
+func ParseGraphOutput(r io.Reader) (*Graph, error) {
+	g := NewGraph()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, `"`) {
+			// digraph header, node attributes and closing brace
+			continue
+		}
+		from, rest, err := parseDOTString(line)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing graph line %d: %w", lineNumber, err)
+		}
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, "->") {
+			for _, label := range strings.Split(from, "\n") {
+				g.AddNode(label)
+			}
+			continue
+		}
+		to, _, err := parseDOTString(strings.TrimSpace(strings.TrimPrefix(rest, "->")))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing graph line %d: %w", lineNumber, err)
+		}
+		for _, fromLabel := range strings.Split(from, "\n") {
+			for _, toLabel := range strings.Split(to, "\n") {
+				g.AddEdge(fromLabel, toLabel)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading graph: %w", err)
+	}
+	return g, nil
+}
+
+// parseDOTString parses the quoted string s starts with and returns its value and what follows it
+func parseDOTString(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", fmt.Errorf("expected a quoted string: %s", s)
+	}
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return value.String(), s[i+1:], nil
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					value.WriteByte('\n')
+				} else {
+					value.WriteByte(s[i])
+				}
+			}
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string: %s", s)
+}
+
+// WriteDOT writes the graph in the DOT language of Graphviz
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n  node [shape=box];\n")
+	for _, label := range g.Nodes() {
+		fmt.Fprintf(&b, "  %s;\n", dotString(label))
+	}
+	for _, edge := range g.Edges() {
+		fmt.Fprintf(&b, "  %s -> %s;\n", dotString(edge.From), dotString(edge.To))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// jsonGraph is the JSON form of a graph
+type jsonGraph struct {
+	Nodes []jsonNode `json:"nodes"`
+	Edges []Edge     `json:"edges"`
+}
+
+type jsonNode struct {
+	Label string `json:"label"`
+	Kind  string `json:"kind,omitempty"`
+}
+
+// WriteJSON writes the graph as a JSON object with the nodes and edges sorted:
+// {"nodes": [{"label": "//a", "kind": "go_library"}], "edges": [{"from": "//a", "to": "//b"}]}
+func (g *Graph) WriteJSON(w io.Writer) error {
+	graph := jsonGraph{Nodes: []jsonNode{}, Edges: g.Edges()}
+	for _, label := range g.Nodes() {
+		graph.Nodes = append(graph.Nodes, jsonNode{Label: label, Kind: g.kinds[label]})
+	}
+	if graph.Edges == nil {
+		graph.Edges = []Edge{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(graph)
+}
+
+// graphML is the GraphML form of a graph, see http://graphml.graphdrawing.org/
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph in the GraphML format, the labels are the node ids and the kinds a "kind" attribute
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  []graphMLKey{{ID: "kind", For: "node", AttrName: "kind", AttrType: "string"}},
+	}
+	doc.Graph.ID = "dependencies"
+	doc.Graph.EdgeDefault = "directed"
+	for _, label := range g.Nodes() {
+		node := graphMLNode{ID: label}
+		if kind := g.kinds[label]; kind != "" {
+			node.Data = []graphMLData{{Key: "kind", Value: kind}}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, edge := range g.Edges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: edge.From, Target: edge.To})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("error writing GraphML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,133 @@
+package bazel
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/bazelshell/bazeltest"
+)
+
+func TestParseGraphOutput(t *testing.T) {
+	g, err := ParseGraphOutput(openTestFile(t, "query.graph"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"//app:app", "//app:main.go", "//lib:a.go", "//lib:b.go", "//lib:lib", "@com_github_pkg_errors//:errors"}, g.Nodes())
+	assert.Equal(t, []string{"//lib:a.go", "//lib:b.go", "@com_github_pkg_errors//:errors"}, g.Deps("//lib:lib"), "Expected factored nodes to be expanded")
+	assert.Equal(t, []string{"//app:app"}, g.RDeps("//lib:lib"))
+	assert.Len(t, g.Edges(), 5)
+
+	_, err = ParseGraphOutput(strings.NewReader("digraph mygraph {\n  \"//a:a\" -> \"//b:b\n}\n"))
+	assert.ErrorContains(t, err, "error parsing graph line 2: unterminated string")
+}
+
+func TestClientQueryGraph(t *testing.T) {
+	fake := bazeltest.New(t, bazeltest.Rule{
+		Pattern: "^query --output=graph --nograph:factored deps\\(//app\\)$",
+		Stdout:  "digraph mygraph {\n  node [shape=box];\n  \"//app:app\" -> \"//lib:lib\"\n}\n",
+	})
+	client := NewClient(t.TempDir())
+	client.Binary = fake.Path
+
+	g, err := client.QueryGraph("deps(//app)", []string{"--nograph:factored"})
+	require.NoError(t, err)
+	assert.Equal(t, []Edge{{From: "//app:app", To: "//lib:lib"}}, g.Edges())
+}
+
+// testGraph returns a graph of labels a to f:
+// a -> b -> d, a -> c -> d -> e, c -> e, and the isolated f
+func testGraph() *Graph {
+	g := NewGraph()
+	for _, edge := range [][2]string{{"a", "b"}, {"a", "c"}, {"b", "d"}, {"c", "d"}, {"c", "e"}, {"d", "e"}} {
+		g.AddEdge(edge[0], edge[1])
+	}
+	g.AddNode("f")
+	return g
+}
+
+func TestGraphTraversal(t *testing.T) {
+	g := testGraph()
+
+	assert.Equal(t, []string{"b", "d", "e"}, g.Reachable("b"))
+	assert.Equal(t, []string{"c", "d", "e", "f"}, g.Reachable("c", "f", "missing"))
+	assert.Equal(t, []string{"a", "c", "e"}, g.ShortestPath("a", "e"))
+	assert.Equal(t, []string{"a", "b", "d"}, g.ShortestPath("a", "d"), "Expected the first path in label order")
+	assert.Equal(t, []string{"d"}, g.ShortestPath("d", "d"))
+	assert.Nil(t, g.ShortestPath("e", "a"))
+	assert.Nil(t, g.ShortestPath("a", "missing"))
+
+	order, err := g.TopologicalOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e", "d", "b", "c", "a", "f"}, order)
+	assert.Empty(t, g.Cycles())
+}
+
+func TestGraphCycles(t *testing.T) {
+	g := testGraph()
+	g.AddEdge("e", "c")
+	g.AddEdge("f", "f")
+
+	assert.Equal(t, [][]string{{"c", "d", "e"}, {"f"}}, g.Cycles())
+	_, err := g.TopologicalOrder()
+	var cycleErr *CycleError
+	require.ErrorAs(t, err, &cycleErr)
+	assert.EqualError(t, err, "dependency graph has 2 cycle(s): [c, d, e], [f]")
+}
+
+func TestGraphFromTargets(t *testing.T) {
+	targets, err := ParseStreamedJSONProto(openTestFile(t, "query.streamed_jsonproto"))
+	require.NoError(t, err)
+
+	g := GraphFromTargets(targets)
+	assert.Len(t, g.Nodes(), len(targets))
+	assert.Equal(t, []string{"//cmd/tool:main.go"}, g.Deps("//cmd/tool:tool_lib"), "Expected inputs outside of the result to be skipped")
+	for _, target := range targets {
+		assert.Equal(t, target.Kind, g.Kind(target.Label))
+		for _, dep := range g.Deps(target.Label) {
+			assert.Contains(t, target.RuleInputs, dep)
+		}
+	}
+}
+
+func TestGraphExport(t *testing.T) {
+	g := NewGraph()
+	g.SetKind("//app:app", "go_binary")
+	g.AddEdge("//app:app", `//lib:say"hi"`)
+
+	var dot bytes.Buffer
+	require.NoError(t, g.WriteDOT(&dot))
+	assert.Equal(t, `digraph dependencies {
+  node [shape=box];
+  "//app:app";
+  "//lib:say\"hi\"";
+  "//app:app" -> "//lib:say\"hi\"";
+}
+`, dot.String())
+	parsed, err := ParseGraphOutput(&dot)
+	require.NoError(t, err)
+	assert.Equal(t, g.Edges(), parsed.Edges())
+
+	var jsonOut bytes.Buffer
+	require.NoError(t, g.WriteJSON(&jsonOut))
+	assert.JSONEq(t, `{
+  "nodes": [{"label": "//app:app", "kind": "go_binary"}, {"label": "//lib:say\"hi\""}],
+  "edges": [{"from": "//app:app", "to": "//lib:say\"hi\""}]
+}`, jsonOut.String())
+
+	var graphML bytes.Buffer
+	require.NoError(t, g.WriteGraphML(&graphML))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="kind" for="node" attr.name="kind" attr.type="string"></key>
+  <graph id="dependencies" edgedefault="directed">
+    <node id="//app:app">
+      <data key="kind">go_binary</data>
+    </node>
+    <node id="//lib:say&#34;hi&#34;"></node>
+    <edge source="//app:app" target="//lib:say&#34;hi&#34;"></edge>
+  </graph>
+</graphml>
+`, graphML.String())
+}
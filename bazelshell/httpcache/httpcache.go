@@ -0,0 +1,194 @@
+// Package httpcache is an in-memory remote cache serving the HTTP caching protocol of Bazel, for tests and
+// small setups: the action cache under /ac/ and the content addressable storage under /cas/, both keyed
+// by SHA-256. Point Bazel at it with --remote_cache=http://host:port, optionally with a path prefix
+// separating several caches.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Stats counts the requests served by the cache since it was started or reset
+type Stats struct {
+	ACHits    int
+	ACMisses  int
+	CASHits   int
+	CASMisses int
+	// Uploads counts the entries written to either store
+	Uploads         int
+	BytesUploaded   int64
+	BytesDownloaded int64
+}
+
+// Server is the cache, an http.Handler which can also listen on its own with Start
+type Server struct {
+	// RequiredHeaders are checked on each request, requests missing them are rejected with 401 Unauthorized.
+	// Use it to verify the --remote_header flags are passed.
+	RequiredHeaders map[string]string
+
+	mu       sync.Mutex
+	blobs    map[string][]byte
+	stats    Stats
+	listener net.Listener
+	server   *http.Server
+}
+
+// New returns an empty cache
+func New() *Server {
+	return &Server{blobs: map[string][]byte{}}
+}
+
+// Start listens on the given address, i.e. "127.0.0.1:0" for a random port, and serves the cache in the
+// background until Close is called
+func (s *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error starting cache on %s: %w", addr, err)
+	}
+	s.listener = listener
+	s.server = &http.Server{Handler: s}
+	go func() {
+		// Serve always fails, with http.ErrServerClosed once closed
+		_ = s.server.Serve(listener)
+	}()
+	return nil
+}
+
+// URL returns the URL to pass to --remote_cache, empty if the server was not started
+func (s *Server) URL() string {
+	if s.listener == nil {
+		return ""
+	}
+	return "http://" + s.listener.Addr().String()
+}
+
+// Close stops a started server
+func (s *Server) Close() error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Close()
+}
+
+// Stats returns the statistics of the requests served
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Len returns the number of entries stored, action cache and content addressable storage together
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.blobs)
+}
+
+// Reset empties the cache and its statistics
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs = map[string][]byte{}
+	s.stats = Stats{}
+}
+
+// ServeHTTP answers GET, HEAD and PUT requests for /[prefix/]ac/<sha256> and /[prefix/]cas/<sha256>
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for name, value := range s.RequiredHeaders {
+		if r.Header.Get(name) != value {
+			http.Error(w, "missing or invalid header "+name, http.StatusUnauthorized)
+			return
+		}
+	}
+	store, hash, err := parsePath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The prefix is part of the key, separating the caches
+	key := path.Dir(path.Clean(r.URL.Path)) + "/" + hash
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.get(w, r, store, key)
+	case http.MethodPut:
+		s.put(w, r, store, key, hash)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, store, key string) {
+	s.mu.Lock()
+	blob, ok := s.blobs[key]
+	switch {
+	case store == "ac" && ok:
+		s.stats.ACHits++
+	case store == "ac":
+		s.stats.ACMisses++
+	case ok:
+		s.stats.CASHits++
+	default:
+		s.stats.CASMisses++
+	}
+	if ok && r.Method == http.MethodGet {
+		s.stats.BytesDownloaded += int64(len(blob))
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", fmt.Sprint(len(blob)))
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(blob)
+	}
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, store, key, hash string) {
+	blob, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Action results are keyed by the digest of the action, blobs by their own digest
+	if store == "cas" {
+		sum := sha256.Sum256(blob)
+		if actual := hex.EncodeToString(sum[:]); actual != hash {
+			http.Error(w, fmt.Sprintf("digest mismatch: got %s for %s", actual, hash), http.StatusBadRequest)
+			return
+		}
+	}
+	s.mu.Lock()
+	s.blobs[key] = blob
+	s.stats.Uploads++
+	s.stats.BytesUploaded += int64(len(blob))
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+// parsePath returns the store (ac or cas) and the lower case hash of a request path
+func parsePath(urlPath string) (store, hash string, err error) {
+	parts := strings.Split(strings.Trim(urlPath, "/"), "/")
+	if len(parts) < 2 {
+		return "", "", fmt.Errorf("invalid cache path %s, expected [prefix/](ac|cas)/<sha256>", urlPath)
+	}
+	store, hash = parts[len(parts)-2], parts[len(parts)-1]
+	if store != "ac" && store != "cas" {
+		return "", "", fmt.Errorf("invalid cache path %s, expected [prefix/](ac|cas)/<sha256>", urlPath)
+	}
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha256.Size {
+		return "", "", fmt.Errorf("invalid SHA-256 %s", hash)
+	}
+	return store, strings.ToLower(hash), nil
+}
@@ -0,0 +1,121 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func digest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func do(t *testing.T, method, url, body string, headers ...string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(content)
+}
+
+func TestServer(t *testing.T) {
+	cache := New()
+	server := httptest.NewServer(cache)
+	defer server.Close()
+	blob := "package main"
+	actionKey := digest("action")
+
+	status, _ := do(t, http.MethodGet, server.URL+"/ac/"+actionKey, "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = do(t, http.MethodHead, server.URL+"/cas/"+digest(blob), "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = do(t, http.MethodPut, server.URL+"/cas/"+digest(blob), blob)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = do(t, http.MethodPut, server.URL+"/ac/"+actionKey, "result")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, content := do(t, http.MethodGet, server.URL+"/cas/"+strings.ToUpper(digest(blob)), "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, blob, content)
+	status, content = do(t, http.MethodGet, server.URL+"/ac/"+actionKey, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "result", content)
+	status, _ = do(t, http.MethodGet, server.URL+"/other/ac/"+actionKey, "")
+	assert.Equal(t, http.StatusNotFound, status, "Expected prefixes to separate caches")
+
+	assert.Equal(t, 2, cache.Len())
+	assert.Equal(t, Stats{
+		ACHits:          1,
+		ACMisses:        2,
+		CASHits:         1,
+		CASMisses:       1,
+		Uploads:         2,
+		BytesUploaded:   int64(len(blob) + len("result")),
+		BytesDownloaded: int64(len(blob) + len("result")),
+	}, cache.Stats())
+
+	cache.Reset()
+	assert.Equal(t, 0, cache.Len())
+	assert.Equal(t, Stats{}, cache.Stats())
+}
+
+func TestServerInvalidRequests(t *testing.T) {
+	cache := New()
+	cache.RequiredHeaders = map[string]string{"Authorization": "Bearer token"}
+	server := httptest.NewServer(cache)
+	defer server.Close()
+	auth := []string{"Authorization", "Bearer token"}
+
+	var tests = []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		headers  []string
+		expected int
+	}{
+		{name: "missing header", method: http.MethodGet, path: "/ac/" + digest("a"), expected: http.StatusUnauthorized},
+		{name: "wrong header", method: http.MethodGet, path: "/ac/" + digest("a"), headers: []string{"Authorization", "Bearer other"}, expected: http.StatusUnauthorized},
+		{name: "unknown store", method: http.MethodGet, path: "/blobs/" + digest("a"), headers: auth, expected: http.StatusBadRequest},
+		{name: "invalid hash", method: http.MethodGet, path: "/cas/1234", headers: auth, expected: http.StatusBadRequest},
+		{name: "digest mismatch", method: http.MethodPut, path: "/cas/" + digest("a"), body: "b", headers: auth, expected: http.StatusBadRequest},
+		{name: "method", method: http.MethodDelete, path: "/cas/" + digest("a"), headers: auth, expected: http.StatusMethodNotAllowed},
+		{name: "valid", method: http.MethodPut, path: "/cas/" + digest("a"), body: "a", headers: auth, expected: http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _ := do(t, tc.method, server.URL+tc.path, tc.body, tc.headers...)
+			assert.Equal(t, tc.expected, status)
+		})
+	}
+	assert.Equal(t, 1, cache.Len())
+}
+
+func TestServerStart(t *testing.T) {
+	cache := New()
+	assert.Empty(t, cache.URL())
+	require.NoError(t, cache.Start("127.0.0.1:0"))
+	defer cache.Close()
+	assert.True(t, strings.HasPrefix(cache.URL(), "http://127.0.0.1:"))
+
+	resp, err := http.Post(cache.URL()+"/cas/"+digest("a"), "", bytes.NewReader(nil))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,88 @@
+package bazel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/open-ch/go-libs/fsutils"
+)
+
+// Info holds the values reported by bazel info
+// see https://bazel.build/docs/user-manual#info for more details
+type Info struct {
+	Workspace         string
+	ExecutionRoot     string
+	OutputBase        string
+	OutputPath        string
+	BazelBin          string
+	BazelTestlogs     string
+	Release           string
+	StarlarkSemantics string
+	// Values holds all the values returned, by key
+	Values map[string]string
+}
+
+// Version returns the Bazel version from the release, i.e. 7.1.0 for "release 7.1.0"
+func (i *Info) Version() string {
+	return strings.TrimPrefix(i.Release, "release ")
+}
+
+// GetInfo returns the values of the given bazel info keys for the workspace folder, see Client.Info
+func GetInfo(folder string, keys ...string) (*Info, error) {
+	return NewClient(folder).Info(keys...)
+}
+
+// Info returns the values of the given bazel info keys, all the default keys if none is given.
+// The typed fields of keys not requested are left empty.
+func (c *Client) Info(keys ...string) (*Info, error) {
+	result, err := c.Run("info", keys...)
+	if err != nil {
+		return nil, err
+	}
+	output := strings.TrimSuffix(string(result.Stdout), "\n")
+	info := &Info{Values: map[string]string{}}
+	if len(keys) == 1 {
+		// A single value is printed without its key
+		info.Values[keys[0]] = output
+	} else {
+		for _, line := range strings.Split(output, "\n") {
+			if key, value, found := strings.Cut(line, ": "); found {
+				info.Values[key] = value
+			}
+		}
+	}
+	info.Workspace = info.Values["workspace"]
+	info.ExecutionRoot = info.Values["execution_root"]
+	info.OutputBase = info.Values["output_base"]
+	info.OutputPath = info.Values["output_path"]
+	info.BazelBin = info.Values["bazel-bin"]
+	info.BazelTestlogs = info.Values["bazel-testlogs"]
+	info.Release = info.Values["release"]
+	info.StarlarkSemantics = info.Values["starlark-semantics"]
+	return info, nil
+}
+
+// workspaceMarkers are the files marking the root of a workspace, by order of preference
+var workspaceMarkers = []string{"MODULE.bazel", "REPO.bazel", "WORKSPACE.bazel", "WORKSPACE"}
+
+// Workspace is the root of a Bazel workspace
+type Workspace struct {
+	Root string
+	// Marker is the file found at the root, i.e. MODULE.bazel
+	Marker string
+	// Bzlmod is true if the root has a MODULE.bazel file, external dependencies are then managed with bzlmod
+	// unless it is disabled with --noenable_bzlmod
+	Bzlmod bool
+}
+
+// FindWorkspace returns the workspace startPath is in: the closest parent (startPath included)
+// containing one of MODULE.bazel, REPO.bazel, WORKSPACE.bazel or WORKSPACE as a file.
+// Errors other than a missing marker, i.e. a permission denied, are returned.
+func FindWorkspace(startPath string) (*Workspace, error) {
+	root, marker, err := fsutils.SearchClosestParent(startPath, workspaceMarkers, fsutils.SearchOptions{RegularFile: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate a Bazel workspace: %w", err)
+	}
+	// MODULE.bazel is the preferred marker, it is found first when present
+	return &Workspace{Root: root, Marker: marker, Bzlmod: marker == "MODULE.bazel"}, nil
+}
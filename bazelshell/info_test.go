@@ -0,0 +1,97 @@
+package bazel
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientInfo(t *testing.T) {
+	client := NewClient(t.TempDir())
+	client.Binary = writeScript(t, `if [ "$#" -eq 2 ]; then echo /output/base; exit; fi
+printf 'bazel-bin: /exec/bazel-out/k8-fastbuild/bin\n'
+printf 'execution_root: /exec\n'
+printf 'output_base: /output/base\n'
+printf 'release: release 7.1.0\n'
+printf 'starlark-semantics: StarlarkSemantics{enable_bzlmod=true}\n'
+printf 'used-heap-size: 40MB\n'
+printf 'workspace: /workspace\n'`)
+
+	single, err := client.Info("output_base")
+	require.NoError(t, err)
+	assert.Equal(t, "/output/base", single.OutputBase)
+	assert.Equal(t, map[string]string{"output_base": "/output/base"}, single.Values)
+
+	info, err := client.Info()
+	require.NoError(t, err)
+	assert.Equal(t, "/workspace", info.Workspace)
+	assert.Equal(t, "/exec", info.ExecutionRoot)
+	assert.Equal(t, "/exec/bazel-out/k8-fastbuild/bin", info.BazelBin)
+	assert.Equal(t, "7.1.0", info.Version())
+	assert.Equal(t, "StarlarkSemantics{enable_bzlmod=true}", info.StarlarkSemantics)
+	assert.Equal(t, "40MB", info.Values["used-heap-size"])
+	assert.Empty(t, info.BazelTestlogs)
+}
+
+func TestFindWorkspace(t *testing.T) {
+	root := t.TempDir()
+	touch := func(name string) {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+	touch("WORKSPACE")
+	touch("MODULE.bazel")
+	touch("pkg/sub/BUILD")
+	touch("nested/WORKSPACE.bazel")
+	touch("nested/pkg/BUILD.bazel")
+	touch("nested/pkg/file.txt")
+	// Directories named like markers are not workspaces
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "nested/pkg/WORKSPACE"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "nested/MODULE.bazel"), 0755))
+
+	tests := map[string]struct {
+		start  string
+		root   string
+		marker string
+		bzlmod bool
+	}{
+		"root":                  {start: ".", root: ".", marker: "MODULE.bazel", bzlmod: true},
+		"package in root":       {start: "pkg/sub", root: ".", marker: "MODULE.bazel", bzlmod: true},
+		"nested workspace wins": {start: "nested/pkg", root: "nested", marker: "WORKSPACE.bazel"},
+		"start at a file":       {start: "nested/pkg/file.txt", root: "nested", marker: "WORKSPACE.bazel"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			workspace, err := FindWorkspace(filepath.Join(root, tc.start))
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(root, tc.root), workspace.Root)
+			assert.Equal(t, tc.marker, workspace.Marker)
+			assert.Equal(t, tc.bzlmod, workspace.Bzlmod)
+		})
+	}
+}
+
+func TestFindWorkspaceMissing(t *testing.T) {
+	_, err := FindWorkspace(t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestFindWorkspacePermissionDenied(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "MODULE.bazel"), nil, 0644))
+	locked := filepath.Join(root, "locked")
+	require.NoError(t, os.Mkdir(locked, 0755))
+	require.NoError(t, os.Chmod(locked, 0))
+	t.Cleanup(func() { _ = os.Chmod(locked, 0755) })
+
+	_, err := FindWorkspace(filepath.Join(locked, "pkg"))
+	assert.ErrorIs(t, err, fs.ErrPermission, "Expected the workspace not to be searched past an unreadable directory")
+}
@@ -0,0 +1,204 @@
+package bazel
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TestCaseStatus is the outcome of a single test case
+type TestCaseStatus string
+
+// Test case statuses, TestCaseFlaky is only set when aggregating attempts
+const (
+	TestCasePassed  TestCaseStatus = "passed"
+	TestCaseFailed  TestCaseStatus = "failed"
+	TestCaseError   TestCaseStatus = "error"
+	TestCaseSkipped TestCaseStatus = "skipped"
+	TestCaseFlaky   TestCaseStatus = "flaky"
+)
+
+// TestSuite is a test suite of a JUnit XML report, as written to test.xml
+type TestSuite struct {
+	Name      string
+	Tests     int
+	Failures  int
+	Errors    int
+	Skipped   int
+	Duration  time.Duration
+	Timestamp string
+	Cases     []TestCase
+	SystemOut string
+	SystemErr string
+}
+
+// TestCase is a single test case of a test suite
+type TestCase struct {
+	Name      string
+	ClassName string
+	Status    TestCaseStatus
+	Duration  time.Duration
+	// Failure is set for failed cases and cases in error
+	Failure   *TestFailure
+	SystemOut string
+	SystemErr string
+}
+
+// TestFailure describes why a test case failed
+type TestFailure struct {
+	Message string
+	Type    string
+	// Text is the content of the failure element, usually the stack trace or assertion output
+	Text string
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Disabled  int         `xml:"disabled,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+	// Suites may be nested
+	Suites    []junitSuite `xml:"testsuite"`
+	SystemOut string       `xml:"system-out"`
+	SystemErr string       `xml:"system-err"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Status    string        `xml:"status,attr"`
+	Result    string        `xml:"result,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	Error     *junitFailure `xml:"error"`
+	Skipped   *struct{}     `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+	SystemErr string        `xml:"system-err"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnitXML parses a JUnit XML report, with a <testsuites> or a single <testsuite> root.
+// Nested suites are flattened.
+func ParseJUnitXML(r io.Reader) ([]TestSuite, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JUnit XML: %w", err)
+	}
+	var root struct {
+		XMLName xml.Name
+		junitSuite
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("error parsing JUnit XML: %w", err)
+	}
+	var suites []TestSuite
+	switch root.XMLName.Local {
+	case "testsuites":
+		for _, suite := range root.Suites {
+			if suites, err = appendSuite(suites, suite); err != nil {
+				return nil, err
+			}
+		}
+	case "testsuite":
+		if suites, err = appendSuite(suites, root.junitSuite); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("error parsing JUnit XML: unexpected root element <%s>", root.XMLName.Local)
+	}
+	return suites, nil
+}
+
+// appendSuite appends the suite and its nested suites
+func appendSuite(suites []TestSuite, raw junitSuite) ([]TestSuite, error) {
+	duration, err := parseSeconds(raw.Time)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing time of test suite %s: %w", raw.Name, err)
+	}
+	suite := TestSuite{
+		Name:      raw.Name,
+		Tests:     raw.Tests,
+		Failures:  raw.Failures,
+		Errors:    raw.Errors,
+		Skipped:   raw.Skipped + raw.Disabled,
+		Duration:  duration,
+		Timestamp: raw.Timestamp,
+		SystemOut: strings.TrimSpace(raw.SystemOut),
+		SystemErr: strings.TrimSpace(raw.SystemErr),
+	}
+	for _, rawCase := range raw.Cases {
+		testCase, err := rawCase.toTestCase()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing test case %s of %s: %w", rawCase.Name, raw.Name, err)
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	// Suites with cases only in nested suites are not reported on their own
+	if len(raw.Cases) > 0 || len(raw.Suites) == 0 {
+		suites = append(suites, suite)
+	}
+	for _, nested := range raw.Suites {
+		if suites, err = appendSuite(suites, nested); err != nil {
+			return nil, err
+		}
+	}
+	return suites, nil
+}
+
+func (raw junitCase) toTestCase() (TestCase, error) {
+	duration, err := parseSeconds(raw.Time)
+	if err != nil {
+		return TestCase{}, err
+	}
+	testCase := TestCase{
+		Name:      raw.Name,
+		ClassName: raw.ClassName,
+		Status:    TestCasePassed,
+		Duration:  duration,
+		SystemOut: strings.TrimSpace(raw.SystemOut),
+		SystemErr: strings.TrimSpace(raw.SystemErr),
+	}
+	switch {
+	case raw.Failure != nil:
+		testCase.Status = TestCaseFailed
+		testCase.Failure = raw.Failure.toTestFailure()
+	case raw.Error != nil:
+		testCase.Status = TestCaseError
+		testCase.Failure = raw.Error.toTestFailure()
+	case raw.Skipped != nil || raw.Status == "notrun" || raw.Result == "suppressed" || raw.Result == "skipped":
+		// The status and result attributes are written by googletest for disabled and skipped tests
+		testCase.Status = TestCaseSkipped
+	}
+	return testCase, nil
+}
+
+func (raw *junitFailure) toTestFailure() *TestFailure {
+	return &TestFailure{Message: raw.Message, Type: raw.Type, Text: strings.TrimSpace(raw.Text)}
+}
+
+// parseSeconds parses a duration in seconds with decimals, i.e. 0.123; empty is zero.
+// Thousands separators written by some reporters (1,234.5) are ignored.
+func parseSeconds(s string) (time.Duration, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	if s == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
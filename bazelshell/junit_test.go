@@ -0,0 +1,81 @@
+package bazel
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJUnitXML(t *testing.T) {
+	suites, err := ParseJUnitXML(openTestFile(t, "test.xml"))
+	require.NoError(t, err)
+	require.Len(t, suites, 2, "Expected the nested suite to be flattened")
+
+	assert.Equal(t, "lib/lib_test", suites[0].Name)
+	assert.Equal(t, 1234500*time.Millisecond, suites[0].Duration)
+	assert.Equal(t, 1, suites[0].Skipped)
+	assert.Equal(t, "2023-06-01T10:00:00", suites[0].Timestamp)
+	assert.Equal(t, []TestCase{
+		{
+			Name: "TestConnect", ClassName: "lib", Status: TestCaseError, Duration: 1500 * time.Millisecond,
+			Failure:   &TestFailure{Message: "panic", Type: "runtime.Error", Text: "panic: nil pointer dereference"},
+			SystemOut: "connecting",
+		},
+		{Name: "TestSkipped", ClassName: "lib", Status: TestCaseSkipped},
+		{Name: "DISABLED_TestSlow", ClassName: "lib", Status: TestCaseSkipped},
+	}, suites[0].Cases)
+
+	assert.Equal(t, "TestParse", suites[1].Name)
+	assert.Equal(t, 250*time.Millisecond, suites[1].Duration)
+	assert.Equal(t, []TestCase{
+		{Name: "TestParse/valid", ClassName: "lib", Status: TestCasePassed, Duration: 50 * time.Millisecond},
+		{
+			Name: "TestParse/invalid", ClassName: "lib", Status: TestCaseFailed, Duration: 200 * time.Millisecond,
+			Failure: &TestFailure{Message: "Failed", Text: "parse_test.go:42: expected an error"},
+		},
+	}, suites[1].Cases)
+}
+
+func TestParseJUnitXMLErrors(t *testing.T) {
+	var tests = []struct {
+		name        string
+		xml         string
+		expected    []TestSuite
+		expectedErr string
+	}{
+		{
+			name:     "single suite root",
+			xml:      `<testsuite name="s" tests="1"><testcase name="a" time="0.001"/></testsuite>`,
+			expected: []TestSuite{{Name: "s", Tests: 1, Cases: []TestCase{{Name: "a", Status: TestCasePassed, Duration: time.Millisecond}}}},
+		},
+		{
+			name:        "unexpected root",
+			xml:         `<html></html>`,
+			expectedErr: "unexpected root element <html>",
+		},
+		{
+			name:        "invalid xml",
+			xml:         `<testsuites>`,
+			expectedErr: "error parsing JUnit XML",
+		},
+		{
+			name:        "invalid time",
+			xml:         `<testsuite name="s"><testcase name="a" time="soon"/></testsuite>`,
+			expectedErr: "error parsing test case a of s",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			suites, err := ParseJUnitXML(strings.NewReader(tc.xml))
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, suites)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package bazel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Label is a parsed Bazel label, i.e. @repo//some/package:target
+// see https://bazel.build/concepts/labels for the grammar
+type Label struct {
+	// Repo is the repository part including its leading @ or @@ (canonical name).
+	// It is empty for labels starting with //, "@" or "@@" designate the main repository.
+	Repo string
+	// Package is the package path without leading slashes, empty for the root package
+	Package string
+	// Name is the target name, it is never empty
+	Name string
+}
+
+// targetNamePunctuation lists the punctuation allowed in target and package names besides alphanumerics
+const targetNamePunctuation = `!%-@^_"#$&'()*+,;<=>?[]{|}~/.`
+
+// ParseLabel parses an absolute label: one starting with //, @ or @@.
+// Shorthands are expanded: //foo/bar is //foo/bar:bar and @repo is @repo//:repo.
+func ParseLabel(s string) (Label, error) {
+	var label Label
+	rest := s
+	if strings.HasPrefix(s, "@") {
+		idx := strings.Index(s, "//")
+		if idx < 0 {
+			// @repo is a shorthand for @repo//:repo
+			label.Repo = s
+			label.Name = label.RepoName()
+			if label.Name == "" {
+				return Label{}, fmt.Errorf("invalid label %q: missing repository name", s)
+			}
+			return label, validateRepo(s, label.Repo)
+		}
+		label.Repo, rest = s[:idx], s[idx:]
+		if err := validateRepo(s, label.Repo); err != nil {
+			return Label{}, err
+		}
+	}
+	if !strings.HasPrefix(rest, "//") {
+		return Label{}, fmt.Errorf("invalid label %q: absolute labels must start with //, @ or @@", s)
+	}
+	rest = rest[2:]
+
+	pkg, name, hasName := strings.Cut(rest, ":")
+	if !hasName {
+		// //foo/bar is a shorthand for //foo/bar:bar
+		name = pkg[strings.LastIndex(pkg, "/")+1:]
+	}
+	if err := validatePackage(s, pkg); err != nil {
+		return Label{}, err
+	}
+	if err := validateName(s, name); err != nil {
+		return Label{}, err
+	}
+	label.Package = pkg
+	label.Name = name
+	return label, nil
+}
+
+// ParseRelativeLabel parses a label which may be relative to the package of base,
+// i.e. ":target" or "target". Absolute labels are parsed as with ParseLabel.
+func ParseRelativeLabel(s string, base Label) (Label, error) {
+	if strings.HasPrefix(s, "@") || strings.HasPrefix(s, "//") {
+		return ParseLabel(s)
+	}
+	name := strings.TrimPrefix(s, ":")
+	if err := validateName(s, name); err != nil {
+		return Label{}, err
+	}
+	return Label{Repo: base.Repo, Package: base.Package, Name: name}, nil
+}
+
+// MustParseLabel is like ParseLabel but panics if the label is invalid.
+// It is meant for labels known to be valid, i.e. constants.
+func MustParseLabel(s string) Label {
+	label, err := ParseLabel(s)
+	if err != nil {
+		panic(err)
+	}
+	return label
+}
+
+// String returns the label in its canonical form, without shorthands: @repo//package:name
+func (l Label) String() string {
+	return l.Repo + "//" + l.Package + ":" + l.Name
+}
+
+// RepoName returns the name of the repository without @, empty for the main repository
+func (l Label) RepoName() string {
+	return strings.TrimLeft(l.Repo, "@")
+}
+
+// IsCanonicalRepo returns true if the repository is referred to by its canonical name (@@)
+func (l Label) IsCanonicalRepo() bool {
+	return strings.HasPrefix(l.Repo, "@@")
+}
+
+// Equal returns true if both labels designate the same target.
+// Labels without repository, @// and @@// are all in the main repository. Otherwise repositories must be
+// referred to the same way: @repo is an apparent name which is not in general the canonical name @@repo.
+func (l Label) Equal(other Label) bool {
+	return l.repoKey() == other.repoKey() && l.Package == other.Package && l.Name == other.Name
+}
+
+// repoKey returns the repository as written, empty for all the forms of the main repository
+func (l Label) repoKey() string {
+	if l.RepoName() == "" {
+		return ""
+	}
+	return l.Repo
+}
+
+func validateRepo(label, repo string) error {
+	name := strings.TrimPrefix(repo, "@")
+	canonical := strings.HasPrefix(name, "@")
+	name = strings.TrimPrefix(name, "@")
+	if name == "" {
+		// @// and @@// are the main repository
+		return nil
+	}
+	for i, c := range name {
+		switch {
+		case isAlphanumeric(c) || c == '.' || c == '-' || c == '_':
+		case canonical && (c == '+' || c == '~'):
+		default:
+			return fmt.Errorf("invalid label %q: invalid character %q in repository name", label, c)
+		}
+		if i == 0 && !canonical && !isLetter(c) {
+			return fmt.Errorf("invalid label %q: repository names must start with a letter", label)
+		}
+	}
+	return nil
+}
+
+func validatePackage(label, pkg string) error {
+	if pkg == "" {
+		return nil
+	}
+	if err := validatePath(pkg); err != nil {
+		return fmt.Errorf("invalid label %q: invalid package name: %w", label, err)
+	}
+	return nil
+}
+
+func validateName(label, name string) error {
+	if name == "" {
+		return fmt.Errorf("invalid label %q: empty target name", label)
+	}
+	if err := validatePath(name); err != nil {
+		return fmt.Errorf("invalid label %q: invalid target name: %w", label, err)
+	}
+	return nil
+}
+
+// validatePath checks the rules shared by package and target names
+func validatePath(p string) error {
+	for _, c := range p {
+		if !isAlphanumeric(c) && !strings.ContainsRune(targetNamePunctuation, c) {
+			return fmt.Errorf("invalid character %q", c)
+		}
+	}
+	if strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") {
+		return fmt.Errorf("cannot start or end with /")
+	}
+	for _, segment := range strings.Split(p, "/") {
+		switch segment {
+		case "":
+			return fmt.Errorf("cannot contain //")
+		case ".", "..":
+			return fmt.Errorf("cannot contain %s as a path segment", segment)
+		}
+	}
+	return nil
+}
+
+func isLetter(c rune) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isAlphanumeric(c rune) bool {
+	return isLetter(c) || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,126 @@
+package bazel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Label
+		str      string
+	}{
+		{"//foo/bar:baz", Label{Package: "foo/bar", Name: "baz"}, "//foo/bar:baz"},
+		{"//foo/bar", Label{Package: "foo/bar", Name: "bar"}, "//foo/bar:bar"},
+		{"//:root", Label{Name: "root"}, "//:root"},
+		{"//foo:sub/dir/file.txt", Label{Package: "foo", Name: "sub/dir/file.txt"}, "//foo:sub/dir/file.txt"},
+		{"//foo:a+b,c=d~e#f$g(h)i", Label{Package: "foo", Name: "a+b,c=d~e#f$g(h)i"}, "//foo:a+b,c=d~e#f$g(h)i"},
+		{`//foo:!%-@^_"&'*;<>?[]{|}.`, Label{Package: "foo", Name: `!%-@^_"&'*;<>?[]{|}.`}, `//foo:!%-@^_"&'*;<>?[]{|}.`},
+		{"//foo.bar/baz-qux@1:x", Label{Package: "foo.bar/baz-qux@1", Name: "x"}, "//foo.bar/baz-qux@1:x"},
+		{"@repo//foo:bar", Label{Repo: "@repo", Package: "foo", Name: "bar"}, "@repo//foo:bar"},
+		{"@repo//:bar", Label{Repo: "@repo", Name: "bar"}, "@repo//:bar"},
+		{"@repo", Label{Repo: "@repo", Name: "repo"}, "@repo//:repo"},
+		{"@com_github_foo.bar-baz//x", Label{Repo: "@com_github_foo.bar-baz", Package: "x", Name: "x"}, "@com_github_foo.bar-baz//x:x"},
+		{"@//foo:bar", Label{Repo: "@", Package: "foo", Name: "bar"}, "@//foo:bar"},
+		{"@@//foo:bar", Label{Repo: "@@", Package: "foo", Name: "bar"}, "@@//foo:bar"},
+		{"@@rules_go~0.41.0//go:def.bzl", Label{Repo: "@@rules_go~0.41.0", Package: "go", Name: "def.bzl"}, "@@rules_go~0.41.0//go:def.bzl"},
+		{"@@rules_go+//go", Label{Repo: "@@rules_go+", Package: "go", Name: "go"}, "@@rules_go+//go:go"},
+		{"@@_main//:x", Label{Repo: "@@_main", Name: "x"}, "@@_main//:x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			label, err := ParseLabel(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, label)
+			assert.Equal(t, tt.str, label.String())
+			reparsed, err := ParseLabel(label.String())
+			assert.NoError(t, err, "Expected the canonical form to be valid")
+			assert.Equal(t, label, reparsed)
+		})
+	}
+}
+
+func TestParseLabelInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"foo",
+		":foo",
+		"foo/bar:baz",
+		"//",
+		"//foo:",
+		"//foo/:bar",
+		"///foo:bar",
+		"//foo//bar:baz",
+		"//foo/./bar:baz",
+		"//foo/../bar:baz",
+		"//foo:../bar",
+		"//foo:bar/",
+		"//foo:/bar",
+		"//foo:bar:baz",
+		"//foo:bar\\baz",
+		"//foo:bar baz",
+		"//foo bar:baz",
+		"//foo:bär",
+		"//foo:bar\n",
+		"@",
+		"@@",
+		"@repo~1//foo",
+		"@1repo//foo",
+		"@re/po//foo",
+		"@repo//foo:",
+		"@@rules go//foo",
+	}
+	for _, input := range invalid {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseLabel(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseRelativeLabel(t *testing.T) {
+	base := MustParseLabel("@repo//foo/bar:baz")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":qux", "@repo//foo/bar:qux"},
+		{"qux", "@repo//foo/bar:qux"},
+		{"sub/file.txt", "@repo//foo/bar:sub/file.txt"},
+		{"//other:target", "//other:target"},
+		{"@other//x", "@other//x:x"},
+	}
+	for _, tt := range tests {
+		label, err := ParseRelativeLabel(tt.input, base)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, label.String(), tt.input)
+	}
+
+	for _, input := range []string{"", ":", "../x", "a//b", "a:b"} {
+		_, err := ParseRelativeLabel(input, base)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestLabelEqual(t *testing.T) {
+	assert.True(t, MustParseLabel("//foo").Equal(MustParseLabel("//foo:foo")))
+	assert.True(t, MustParseLabel("//foo:bar").Equal(MustParseLabel("@//foo:bar")))
+	assert.True(t, MustParseLabel("@@//foo:bar").Equal(MustParseLabel("@//foo:bar")))
+	assert.True(t, MustParseLabel("@@repo//foo:bar").Equal(MustParseLabel("@@repo//foo:bar")))
+	assert.False(t, MustParseLabel("@repo//foo:bar").Equal(MustParseLabel("@@repo//foo:bar")),
+		"Expected apparent and canonical repository names to differ")
+	assert.False(t, MustParseLabel("@repo//foo:bar").Equal(MustParseLabel("//foo:bar")))
+	assert.False(t, MustParseLabel("//foo:bar").Equal(MustParseLabel("//foo:baz")))
+	assert.False(t, MustParseLabel("//foo:bar").Equal(MustParseLabel("//foo/bar:bar")))
+}
+
+func TestLabelRepo(t *testing.T) {
+	label := MustParseLabel("@@rules_go~//go")
+	assert.Equal(t, "rules_go~", label.RepoName())
+	assert.True(t, label.IsCanonicalRepo())
+	assert.False(t, MustParseLabel("@rules_go//go").IsCanonicalRepo())
+	assert.Panics(t, func() { MustParseLabel("invalid") })
+}
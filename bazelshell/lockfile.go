@@ -0,0 +1,163 @@
+package bazel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Lockfile holds the content of MODULE.bazel.lock.
+// Only lockfiles of Bazel 7.0 (version 3 to 5) hold the resolved module graph, in ModuleDepGraph.
+// Later versions record the registry files read during the resolution but not its result,
+// the module graph of their workspaces is returned by Client.ModGraph.
+type Lockfile struct {
+	Version        int    `json:"lockFileVersion"`
+	ModuleFileHash string `json:"moduleFileHash,omitempty"`
+	// RegistryFileHashes are the hashes of the files downloaded from registries, by URL
+	RegistryFileHashes map[string]string `json:"registryFileHashes,omitempty"`
+	// SelectedYankedVersions are the yanked versions allowed, by module key
+	SelectedYankedVersions map[string]string `json:"selectedYankedVersions,omitempty"`
+	// ModuleDepGraph are the resolved modules by key
+	ModuleDepGraph map[string]LockfileModule `json:"moduleDepGraph,omitempty"`
+	// ModuleExtensions are the evaluated module extensions, by extension ID. Their format depends on the version.
+	ModuleExtensions map[string]json.RawMessage `json:"moduleExtensions,omitempty"`
+}
+
+// LockfileModule is a resolved module of the lockfile
+type LockfileModule struct {
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// RepoName is the apparent name of the module for itself
+	RepoName string `json:"repoName"`
+	// Deps maps apparent names to the keys of the dependencies
+	Deps            map[string]string        `json:"deps"`
+	ExtensionUsages []LockfileExtensionUsage `json:"extensionUsages"`
+	RepoSpec        *RepoSpec                `json:"repoSpec,omitempty"`
+}
+
+// LockfileExtensionUsage is the use of a module extension recorded in the lockfile
+type LockfileExtensionUsage struct {
+	ExtensionBzlFile      string            `json:"extensionBzlFile"`
+	ExtensionName         string            `json:"extensionName"`
+	UsingModule           string            `json:"usingModule"`
+	Imports               map[string]string `json:"imports"`
+	HasDevUseExtension    bool              `json:"hasDevUseExtension"`
+	HasNonDevUseExtension bool              `json:"hasNonDevUseExtension"`
+}
+
+// RepoSpec is how the repository of a module is fetched
+type RepoSpec struct {
+	BzlFile       string                 `json:"bzlFile"`
+	RuleClassName string                 `json:"ruleClassName"`
+	Attributes    map[string]interface{} `json:"attributes"`
+}
+
+// ReadLockfile reads a MODULE.bazel.lock file
+func ReadLockfile(path string) (*Lockfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading lockfile: %w", err)
+	}
+	defer f.Close()
+	return ParseLockfile(f)
+}
+
+// ReadWorkspaceLockfile reads the MODULE.bazel.lock file at the root of the workspace
+func ReadWorkspaceLockfile(workspace *Workspace) (*Lockfile, error) {
+	return ReadLockfile(filepath.Join(workspace.Root, "MODULE.bazel.lock"))
+}
+
+// ParseLockfile parses the content of a MODULE.bazel.lock file
+func ParseLockfile(r io.Reader) (*Lockfile, error) {
+	var lockfile Lockfile
+	if err := json.NewDecoder(r).Decode(&lockfile); err != nil {
+		return nil, fmt.Errorf("error parsing lockfile: %w", err)
+	}
+	return &lockfile, nil
+}
+
+// HasModuleGraph returns true if the lockfile holds the resolved module graph, see Graph
+func (l *Lockfile) HasModuleGraph() bool {
+	_, ok := l.ModuleDepGraph[RootModuleKey]
+	return ok
+}
+
+// Graph returns the module graph of the lockfile, rooted at the root module.
+// Modules are shared between the modules depending on them, only the first occurrence is expanded
+// like in bazel mod graph. It fails if the lockfile holds no module graph, for lockfiles of versions
+// other than 3 to 5 use Client.ModGraph instead.
+func (l *Lockfile) Graph() (*Module, error) {
+	if !l.HasModuleGraph() {
+		return nil, fmt.Errorf("lockfile version %d holds no module graph, use bazel mod graph instead", l.Version)
+	}
+	expanded := map[string]bool{}
+	var build func(key, apparentName string) *Module
+	build = func(key, apparentName string) *Module {
+		locked := l.ModuleDepGraph[key]
+		module := &Module{
+			Key:          key,
+			Name:         locked.Name,
+			Version:      locked.Version,
+			ApparentName: apparentName,
+			Root:         key == RootModuleKey,
+		}
+		if expanded[key] {
+			module.Unexpanded = true
+			return module
+		}
+		expanded[key] = true
+		apparentNames := make([]string, 0, len(locked.Deps))
+		for name := range locked.Deps {
+			apparentNames = append(apparentNames, name)
+		}
+		sort.Strings(apparentNames)
+		for _, name := range apparentNames {
+			// Built-in modules such as bazel_tools are not part of the graph
+			if _, ok := l.ModuleDepGraph[locked.Deps[name]]; ok {
+				module.Dependencies = append(module.Dependencies, build(locked.Deps[name], name))
+			}
+		}
+		return module
+	}
+	return build(RootModuleKey, l.ModuleDepGraph[RootModuleKey].RepoName), nil
+}
+
+// ExtensionUsages returns the module extensions used by the module with the given key, i.e. <root>
+func (l *Lockfile) ExtensionUsages(key string) []ExtensionUsage {
+	var usages []ExtensionUsage
+	for _, locked := range l.ModuleDepGraph[key].ExtensionUsages {
+		usage := ExtensionUsage{
+			BzlFile:       locked.ExtensionBzlFile,
+			Name:          locked.ExtensionName,
+			DevDependency: locked.HasDevUseExtension && !locked.HasNonDevUseExtension,
+		}
+		for repo := range locked.Imports {
+			usage.Repos = append(usage.Repos, repo)
+		}
+		sort.Strings(usage.Repos)
+		usages = append(usages, usage)
+	}
+	return usages
+}
+
+// RegistryModules returns the modules whose MODULE.bazel files were downloaded from a registry,
+// as name@version keys sorted. This is not the resolved module graph: it includes the versions
+// which were not selected and misses the modules overridden with non-registry overrides.
+func (l *Lockfile) RegistryModules() []string {
+	var modules []string
+	for url := range l.RegistryFileHashes {
+		// https://bcr.bazel.build/modules/<name>/<version>/MODULE.bazel
+		parts := strings.Split(url, "/")
+		if len(parts) < 4 || parts[len(parts)-1] != "MODULE.bazel" || parts[len(parts)-4] != "modules" {
+			continue
+		}
+		modules = append(modules, parts[len(parts)-3]+"@"+parts[len(parts)-2])
+	}
+	sort.Strings(modules)
+	return modules
+}
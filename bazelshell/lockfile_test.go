@@ -0,0 +1,57 @@
+package bazel
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLockfileGraph(t *testing.T) {
+	lockfile, err := ReadLockfile(filepath.Join(getTestDir(), "graph.MODULE.bazel.lock"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, lockfile.Version)
+	assert.Len(t, lockfile.ModuleDepGraph, 4)
+	assert.Equal(t, "http_archive", lockfile.ModuleDepGraph["gazelle@0.33.0"].RepoSpec.RuleClassName)
+	assert.Contains(t, lockfile.ModuleExtensions, "@@rules_go~0.41.0//go:extensions.bzl%go_sdk")
+	assert.True(t, lockfile.HasModuleGraph())
+
+	root, err := lockfile.Graph()
+	require.NoError(t, err)
+	assert.Equal(t, "example", root.ApparentName)
+	require.Len(t, root.Dependencies, 2, "bazel_tools is not in the graph")
+	gazelle, rulesGo := root.Dependencies[0], root.Dependencies[1]
+	assert.Equal(t, "gazelle@0.33.0", gazelle.Key)
+	require.Len(t, gazelle.Dependencies, 1)
+	assert.Equal(t, "io_bazel_rules_go", gazelle.Dependencies[0].ApparentName)
+	assert.False(t, gazelle.Dependencies[0].Unexpanded)
+	assert.Equal(t, "rules_go@0.41.0", rulesGo.Key)
+	assert.True(t, rulesGo.Unexpanded)
+	assert.Len(t, root.Modules(), 4)
+
+	assert.Equal(t, []ExtensionUsage{
+		{BzlFile: "@rules_go//go:extensions.bzl", Name: "go_sdk", Repos: []string{"go_toolchains"}},
+	}, lockfile.ExtensionUsages(RootModuleKey))
+	assert.Empty(t, lockfile.ExtensionUsages("platforms@0.0.7"))
+}
+
+func TestReadLockfileRegistry(t *testing.T) {
+	lockfile, err := ReadLockfile(filepath.Join(getTestDir(), "registry.MODULE.bazel.lock"))
+	require.NoError(t, err)
+	assert.Equal(t, 11, lockfile.Version)
+	assert.Equal(t, []string{"platforms@0.0.7", "rules_go@0.41.0"}, lockfile.RegistryModules())
+
+	assert.False(t, lockfile.HasModuleGraph())
+	_, err = lockfile.Graph()
+	assert.ErrorContains(t, err, "lockfile version 11 holds no module graph")
+}
+
+func TestReadLockfileErrors(t *testing.T) {
+	_, err := ReadLockfile(filepath.Join(getTestDir(), "missing.lock"))
+	assert.ErrorContains(t, err, "error reading lockfile")
+
+	_, err = ParseLockfile(strings.NewReader("{"))
+	assert.ErrorContains(t, err, "error parsing lockfile")
+}
@@ -0,0 +1,285 @@
+package bazel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// RootModuleKey is the key of the root module in the module graph
+const RootModuleKey = "<root>"
+
+// Module is a node of the resolved bzlmod dependency graph, as printed by bazel mod graph --output=json
+type Module struct {
+	// Key identifies the module in the graph: name@version, <root> for the root module
+	// and name@_ for modules with a non registry override
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// ApparentName is the name the module is visible as to the module depending on it
+	ApparentName string `json:"apparentName"`
+	Root         bool   `json:"root"`
+	// Unexpanded is set on modules already printed elsewhere in the graph, their dependencies are not repeated
+	Unexpanded           bool      `json:"unexpanded"`
+	Dependencies         []*Module `json:"dependencies"`
+	IndirectDependencies []*Module `json:"indirectDependencies"`
+	Cycles               []*Module `json:"cycles"`
+}
+
+// ModGraph returns the resolved module graph of the workspace, rooted at the root module
+func (c *Client) ModGraph(flags []string) (*Module, error) {
+	result, err := c.Run("mod", append([]string{"graph", "--output=json"}, flags...)...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseModGraph(bytes.NewReader(result.Stdout))
+}
+
+// ParseModGraph parses the output of bazel mod graph --output=json
+func ParseModGraph(r io.Reader) (*Module, error) {
+	var root Module
+	if err := json.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("error parsing module graph: %w", err)
+	}
+	return &root, nil
+}
+
+// Modules returns the distinct modules of the graph starting at m, m included, sorted by key.
+// Modules appearing several times are returned once, expanded if they are anywhere.
+func (m *Module) Modules() []*Module {
+	byKey := map[string]*Module{}
+	var walk func(module *Module)
+	walk = func(module *Module) {
+		if seen, ok := byKey[module.Key]; ok && (!seen.Unexpanded || module.Unexpanded) {
+			return
+		}
+		byKey[module.Key] = module
+		for _, dep := range module.Dependencies {
+			walk(dep)
+		}
+		for _, dep := range module.IndirectDependencies {
+			walk(dep)
+		}
+	}
+	walk(m)
+	modules := make([]*Module, 0, len(byKey))
+	for _, module := range byKey {
+		modules = append(modules, module)
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Key < modules[j].Key
+	})
+	return modules
+}
+
+// Find returns the module with the given name in the graph starting at m, nil if there is none
+func (m *Module) Find(name string) *Module {
+	for _, module := range m.Modules() {
+		if module.Name == name {
+			return module
+		}
+	}
+	return nil
+}
+
+// Repo is the definition of an external repository, as printed by bazel mod show_repo
+type Repo struct {
+	// Name is the repository as requested, i.e. @rules_go or @@rules_go~
+	Name string
+	// Kind is the repository rule, i.e. http_archive or local_repository
+	Kind string
+	// CanonicalName is the name of the repository on disk, i.e. rules_go~ or rules_go+
+	CanonicalName string
+	// Rule gives access to the attributes of the repository rule
+	Rule *build.Rule
+}
+
+// ShowRepo returns the definitions of the given repositories, i.e. @rules_go or @@rules_go~
+func (c *Client) ShowRepo(repos ...string) ([]Repo, error) {
+	result, err := c.Run("mod", append([]string{"show_repo"}, repos...)...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseShowRepo(bytes.NewReader(result.Stdout))
+}
+
+// ParseShowRepo parses the output of bazel mod show_repo: a Starlark definition for each repository,
+// preceded by a ## @name: header
+func ParseShowRepo(r io.Reader) ([]Repo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repository definitions: %w", err)
+	}
+	var repos []Repo
+	var name string
+	var body []string
+	flush := func() error {
+		if name == "" {
+			return nil
+		}
+		f, err := build.Parse(name, []byte(strings.Join(body, "\n")))
+		if err != nil {
+			return fmt.Errorf("error parsing definition of %s: %w", name, err)
+		}
+		rules := f.Rules("")
+		if len(rules) != 1 {
+			return fmt.Errorf("expected a single repository rule for %s, found %d", name, len(rules))
+		}
+		repos = append(repos, Repo{Name: name, Kind: rules[0].Kind(), CanonicalName: rules[0].Name(), Rule: rules[0]})
+		return nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "## ") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			name = strings.TrimSuffix(strings.TrimPrefix(line, "## "), ":")
+			body = nil
+			continue
+		}
+		body = append(body, line)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// ModuleFile holds the declarations of a MODULE.bazel file
+type ModuleFile struct {
+	Name    string
+	Version string
+	Deps    []BazelDep
+	// ExtensionUsages are the module extensions used, in order
+	ExtensionUsages []ExtensionUsage
+	// File is the parsed file, for declarations without typed fields (overrides, toolchains, ...)
+	File *build.File
+}
+
+// BazelDep is a bazel_dep declaration
+type BazelDep struct {
+	Name    string
+	Version string
+	// RepoName is the apparent name of the dependency, defaults to Name
+	RepoName      string
+	DevDependency bool
+}
+
+// ExtensionUsage is the use of a module extension by a module
+type ExtensionUsage struct {
+	// BzlFile is the label of the file defining the extension, i.e. @rules_go//go:extensions.bzl
+	BzlFile string
+	// Name is the name of the extension in BzlFile, i.e. go_sdk
+	Name string
+	// Repos are the repositories of the extension imported with use_repo, by apparent name
+	Repos         []string
+	DevDependency bool
+}
+
+// ID returns the extension as written in bazel mod commands, i.e. @rules_go//go:extensions.bzl%go_sdk
+func (u ExtensionUsage) ID() string {
+	return u.BzlFile + "%" + u.Name
+}
+
+// ReadModuleFile reads the declarations of a MODULE.bazel file
+func ReadModuleFile(path string) (*ModuleFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	f, err := build.Parse(path, data)
+	if err != nil {
+		return nil, err
+	}
+	return newModuleFile(f), nil
+}
+
+func newModuleFile(f *build.File) *ModuleFile {
+	moduleFile := &ModuleFile{File: f}
+	// Extension usages by the variable the proxy returned by use_extension is assigned to
+	proxies := map[string]int{}
+	for _, stmt := range f.Stmt {
+		if assign, ok := stmt.(*build.AssignExpr); ok {
+			ident, isIdent := assign.LHS.(*build.Ident)
+			call, isCall := assign.RHS.(*build.CallExpr)
+			if !isIdent || !isCall {
+				continue
+			}
+			rule := f.Rule(call)
+			if rule.Kind() != "use_extension" || len(call.List) < 2 {
+				continue
+			}
+			proxies[ident.Name] = len(moduleFile.ExtensionUsages)
+			moduleFile.ExtensionUsages = append(moduleFile.ExtensionUsages, ExtensionUsage{
+				BzlFile:       stringValue(call.List[0]),
+				Name:          stringValue(call.List[1]),
+				DevDependency: isTrue(rule.Attr("dev_dependency")),
+			})
+			continue
+		}
+		call, ok := stmt.(*build.CallExpr)
+		if !ok {
+			continue
+		}
+		rule := f.Rule(call)
+		switch rule.Kind() {
+		case "module":
+			moduleFile.Name = rule.Name()
+			moduleFile.Version = rule.AttrString("version")
+		case "bazel_dep":
+			dep := BazelDep{
+				Name:          rule.Name(),
+				Version:       rule.AttrString("version"),
+				RepoName:      rule.AttrString("repo_name"),
+				DevDependency: isTrue(rule.Attr("dev_dependency")),
+			}
+			if dep.RepoName == "" {
+				dep.RepoName = dep.Name
+			}
+			moduleFile.Deps = append(moduleFile.Deps, dep)
+		case "use_repo":
+			if len(call.List) == 0 {
+				continue
+			}
+			proxy, ok := call.List[0].(*build.Ident)
+			if !ok {
+				continue
+			}
+			i, ok := proxies[proxy.Name]
+			if !ok {
+				continue
+			}
+			for _, arg := range call.List[1:] {
+				// Repositories are imported as is, or under another name: use_repo(ext, my_name = "name")
+				if assign, ok := arg.(*build.AssignExpr); ok {
+					if ident, ok := assign.LHS.(*build.Ident); ok {
+						moduleFile.ExtensionUsages[i].Repos = append(moduleFile.ExtensionUsages[i].Repos, ident.Name)
+					}
+				} else if repo := stringValue(arg); repo != "" {
+					moduleFile.ExtensionUsages[i].Repos = append(moduleFile.ExtensionUsages[i].Repos, repo)
+				}
+			}
+		}
+	}
+	return moduleFile
+}
+
+// stringValue returns the value of a string literal, empty for other expressions
+func stringValue(x build.Expr) string {
+	if str, ok := x.(*build.StringExpr); ok {
+		return str.Value
+	}
+	return ""
+}
+
+// isTrue returns true if the expression is the True literal
+func isTrue(x build.Expr) bool {
+	ident, ok := x.(*build.Ident)
+	return ok && ident.Name == "True"
+}
@@ -0,0 +1,82 @@
+package bazel
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModGraph(t *testing.T) {
+	root, err := ParseModGraph(openTestFile(t, "mod_graph.json"))
+	require.NoError(t, err)
+	assert.True(t, root.Root)
+	assert.Equal(t, RootModuleKey, root.Key)
+	require.Len(t, root.Dependencies, 3)
+	assert.Equal(t, "bazel_gazelle", root.Dependencies[0].ApparentName)
+	assert.True(t, root.Dependencies[1].Unexpanded)
+
+	var keys []string
+	for _, module := range root.Modules() {
+		keys = append(keys, module.Key)
+	}
+	assert.Equal(t, []string{"<root>", "gazelle@0.33.0", "my_tools@_", "platforms@0.0.7", "rules_go@0.41.0"}, keys)
+
+	rulesGo := root.Find("rules_go")
+	require.NotNil(t, rulesGo)
+	assert.False(t, rulesGo.Unexpanded, "the expanded occurrence is returned")
+	assert.Len(t, rulesGo.Dependencies, 1)
+	assert.Nil(t, root.Find("missing"))
+}
+
+func TestClientModGraph(t *testing.T) {
+	client := NewClient(t.TempDir())
+	client.Binary = writeScript(t, `[ "$1 $2 $3 $4" = "mod graph --output=json --depth=1" ] || exit 2
+echo '{"key": "<root>", "name": "example", "root": true, "dependencies": [{"key": "rules_go@0.41.0", "name": "rules_go"}]}'`)
+
+	root, err := client.ModGraph([]string{"--depth=1"})
+	require.NoError(t, err)
+	assert.Equal(t, "example", root.Name)
+	require.Len(t, root.Dependencies, 1)
+	assert.Equal(t, "rules_go@0.41.0", root.Dependencies[0].Key)
+}
+
+func TestParseShowRepo(t *testing.T) {
+	repos, err := ParseShowRepo(openTestFile(t, "show_repo.txt"))
+	require.NoError(t, err)
+	require.Len(t, repos, 2)
+
+	assert.Equal(t, "@rules_go", repos[0].Name)
+	assert.Equal(t, "http_archive", repos[0].Kind)
+	assert.Equal(t, "rules_go~", repos[0].CanonicalName)
+	assert.Equal(t, []string{"https://github.com/bazelbuild/rules_go/releases/download/v0.41.0/rules_go-v0.41.0.zip"}, repos[0].Rule.AttrStrings("urls"))
+
+	assert.Equal(t, "@@my_tools~", repos[1].Name)
+	assert.Equal(t, "local_repository", repos[1].Kind)
+	assert.Equal(t, "tools/my_tools", repos[1].Rule.AttrString("path"))
+}
+
+func TestParseShowRepoInvalid(t *testing.T) {
+	_, err := ParseShowRepo(strings.NewReader("## @broken:\nhttp_archive(\n"))
+	assert.ErrorContains(t, err, "error parsing definition of @broken")
+}
+
+func TestReadModuleFile(t *testing.T) {
+	moduleFile, err := ReadModuleFile(filepath.Join(getTestDir(), "example.MODULE.bazel"))
+	require.NoError(t, err)
+	assert.Equal(t, "example", moduleFile.Name)
+	assert.Equal(t, "1.0.0", moduleFile.Version)
+	assert.Equal(t, []BazelDep{
+		{Name: "rules_go", Version: "0.41.0", RepoName: "rules_go"},
+		{Name: "gazelle", Version: "0.33.0", RepoName: "bazel_gazelle"},
+		{Name: "rules_testing", Version: "0.4.0", RepoName: "rules_testing", DevDependency: true},
+	}, moduleFile.Deps)
+	assert.Equal(t, []ExtensionUsage{
+		{BzlFile: "@rules_go//go:extensions.bzl", Name: "go_sdk", Repos: []string{"go_toolchains"}},
+		{BzlFile: "@bazel_gazelle//:extensions.bzl", Name: "go_deps", Repos: []string{"com_github_pkg_errors", "testify"}},
+		{BzlFile: "//tools:dev.bzl", Name: "dev", DevDependency: true},
+	}, moduleFile.ExtensionUsages)
+	assert.Equal(t, "@rules_go//go:extensions.bzl%go_sdk", moduleFile.ExtensionUsages[0].ID())
+}
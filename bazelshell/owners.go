@@ -0,0 +1,181 @@
+package bazel
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/open-ch/go-libs/bazelshell/query"
+	"github.com/open-ch/go-libs/fsutils"
+)
+
+// FileOwner tells which package and targets own a file of the workspace
+type FileOwner struct {
+	// File is the path of the file relative to the workspace
+	File string
+	// Package is the package the file belongs to, empty for the root package
+	Package string
+	// InPackage is false for files outside of any package, Package and Label are then empty
+	InPackage bool
+	// Label is the source file label of the file, i.e. //pkg:dir/file.go
+	Label string
+	// Owners are the rules of the package with the file in their srcs, sorted.
+	// They are only set by Client.FileOwners.
+	Owners []string
+}
+
+// buildFileNames are the names of BUILD files, by order of precedence
+var buildFileNames = []string{"BUILD.bazel", "BUILD"}
+
+// FindPackage returns the package of the closest directory containing a BUILD or BUILD.bazel file,
+// dir included, up to the workspace root. dir is relative to the workspace and does not need to exist.
+// It returns false if the directory is not in any package.
+func FindPackage(workspace, dir string) (string, bool) {
+	dir = path.Clean(dir)
+	if dir == ".." || strings.HasPrefix(dir, "../") || path.IsAbs(dir) {
+		return "", false
+	}
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return "", false
+	}
+	// Any error means there is no BUILD file here, i.e. dir does not exist or is a file
+	found, _, err := fsutils.SearchClosestParent(filepath.Join(root, filepath.FromSlash(dir)), buildFileNames,
+		fsutils.SearchOptions{RegularFile: true, IgnoreErrors: true, StopAt: root})
+	if err != nil {
+		return "", false
+	}
+	pkg, err := filepath.Rel(root, found)
+	if err != nil {
+		return "", false
+	}
+	if pkg == "." {
+		return "", true
+	}
+	return filepath.ToSlash(pkg), true
+}
+
+// isWithin returns true if path is dir or within it, both absolute and clean
+func isWithin(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// LocalFileOwners maps files to their packages and source file labels using the file system only,
+// see FindPackage. Files are relative to the workspace or absolute. Owners are not set.
+func LocalFileOwners(workspace string, files []string) ([]FileOwner, error) {
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving workspace %s: %w", workspace, err)
+	}
+	owners := make([]FileOwner, 0, len(files))
+	for _, file := range files {
+		if filepath.IsAbs(file) {
+			rel, err := filepath.Rel(root, file)
+			if err != nil || !isWithin(root, filepath.Clean(file)) {
+				return nil, fmt.Errorf("file %s is not within workspace %s", file, root)
+			}
+			file = rel
+		}
+		owner := FileOwner{File: path.Clean(filepath.ToSlash(file))}
+		if owner.File == ".." || strings.HasPrefix(owner.File, "../") {
+			return nil, fmt.Errorf("file %s is not within workspace %s", file, root)
+		}
+		owner.Package, owner.InPackage = FindPackage(root, path.Dir(owner.File))
+		if owner.InPackage {
+			owner.Label = "//" + owner.Package + ":" + strings.TrimPrefix(strings.TrimPrefix(owner.File, owner.Package), "/")
+		}
+		owners = append(owners, owner)
+	}
+	return owners, nil
+}
+
+// FileOwners maps files of the workspace folder to their packages, labels and owning rules,
+// see Client.FileOwners
+func FileOwners(folder string, files []string, flags []string) ([]FileOwner, error) {
+	return NewClient(folder).FileOwners(files, flags)
+}
+
+// FileOwners maps files to their packages, source file labels and the rules having them in their srcs.
+// Packages are found on the file system (LocalFileOwners), the owners with a single query for all the files:
+// set(labels) + attr(srcs, labels, packages). Files which are not source files of their package
+// (i.e. not existing or in a subpackage) are returned without label.
+// Errors in the query are tolerated with --keep_going.
+func (c *Client) FileOwners(files []string, flags []string) ([]FileOwner, error) {
+	owners, err := LocalFileOwners(c.WorkspaceDir, files)
+	if err != nil {
+		return nil, err
+	}
+	var labels, patterns, escaped []string
+	packages := map[string]bool{}
+	for _, owner := range owners {
+		if !owner.InPackage {
+			continue
+		}
+		labels = append(labels, owner.Label)
+		escaped = append(escaped, regexp.QuoteMeta(owner.Label))
+		if !packages[owner.Package] {
+			packages[owner.Package] = true
+			patterns = append(patterns, "//"+owner.Package+":all")
+		}
+	}
+	if len(labels) == 0 {
+		return owners, nil
+	}
+	// attr matches the string form of the srcs: [//pkg:a.go, //pkg:b.go], labels of the main repository
+	// may be prefixed with @ or @@
+	srcsPattern := `(^|[\[ ])@{0,2}(` + strings.Join(escaped, "|") + `)(,|\]|$)`
+	set, err := query.Set(labels...)
+	if err != nil {
+		return nil, err
+	}
+	packageWords, err := query.Words(patterns...)
+	if err != nil {
+		return nil, err
+	}
+	srcs, err := query.Attr("srcs", srcsPattern, query.Union(packageWords...))
+	if err != nil {
+		return nil, err
+	}
+	targets, err := c.QueryTargets(query.Union(set, srcs).String(), append([]string{"--keep_going"}, flags...))
+	if err != nil {
+		return nil, err
+	}
+
+	sourceFiles := map[string]bool{}
+	rulesBySource := map[string][]string{}
+	for _, target := range targets {
+		switch target.Type {
+		case SourceFileTarget:
+			sourceFiles[normalizeLabel(target.Label)] = true
+		case RuleTarget:
+			srcs, _ := target.Attr("srcs")
+			for _, src := range srcs.StringListValue {
+				src = normalizeLabel(src)
+				rulesBySource[src] = append(rulesBySource[src], normalizeLabel(target.Label))
+			}
+		}
+	}
+	for i := range owners {
+		if !owners[i].InPackage {
+			continue
+		}
+		if !sourceFiles[owners[i].Label] {
+			owners[i].Label = ""
+			continue
+		}
+		owners[i].Owners = rulesBySource[owners[i].Label]
+		sort.Strings(owners[i].Owners)
+	}
+	return owners, nil
+}
+
+// normalizeLabel returns labels of the main repository without repository, @@//pkg:name as //pkg:name
+func normalizeLabel(label string) string {
+	if strings.HasPrefix(label, "@//") || strings.HasPrefix(label, "@@//") {
+		return label[strings.Index(label, "//"):]
+	}
+	return label
+}
@@ -0,0 +1,126 @@
+package bazel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/bazelshell/bazeltest"
+)
+
+// writeWorkspace creates the given files in a temporary directory and returns its path
+func writeWorkspace(t *testing.T, files ...string) string {
+	workspace := t.TempDir()
+	for _, file := range files {
+		path := filepath.Join(workspace, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+	return workspace
+}
+
+func TestFindPackage(t *testing.T) {
+	workspace := writeWorkspace(t,
+		"MODULE.bazel",
+		"lib/BUILD.bazel",
+		"lib/internal/util.go",
+		"lib/sub/BUILD",
+		"tools/BUILD/README.md", // A directory named like a BUILD file
+		"tools/gen/gen.sh",
+	)
+
+	var tests = []struct {
+		dir       string
+		expected  string
+		inPackage bool
+	}{
+		{dir: "lib", expected: "lib", inPackage: true},
+		{dir: "lib/internal", expected: "lib", inPackage: true},
+		{dir: "lib/sub/deep/missing", expected: "lib/sub", inPackage: true},
+		{dir: "lib/internal/util.go/nested", expected: "lib", inPackage: true},
+		{dir: "tools/gen", inPackage: false},
+		{dir: ".", inPackage: false},
+		{dir: "../lib", inPackage: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.dir, func(t *testing.T) {
+			pkg, ok := FindPackage(workspace, tc.dir)
+			assert.Equal(t, tc.inPackage, ok)
+			assert.Equal(t, tc.expected, pkg)
+		})
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "BUILD"), nil, 0644))
+	pkg, ok := FindPackage(workspace, "tools/gen")
+	assert.True(t, ok)
+	assert.Equal(t, "", pkg, "Expected the root package")
+}
+
+func TestFindPackageStopsAtWorkspaceRoot(t *testing.T) {
+	parent := writeWorkspace(t, "BUILD", "ws/MODULE.bazel", "ws/tools/gen.sh")
+
+	pkg, ok := FindPackage(filepath.Join(parent, "ws"), "tools")
+	assert.False(t, ok, "Expected the BUILD file above the workspace to be ignored, got package %q", pkg)
+}
+
+func TestLocalFileOwners(t *testing.T) {
+	workspace := writeWorkspace(t, "lib/BUILD", "lib/a.go", "lib/internal/b.go", "BUILD", "README.md")
+
+	owners, err := LocalFileOwners(workspace, []string{"lib/a.go", filepath.Join(workspace, "lib", "internal", "b.go"), "./README.md"})
+	require.NoError(t, err)
+	assert.Equal(t, []FileOwner{
+		{File: "lib/a.go", Package: "lib", InPackage: true, Label: "//lib:a.go"},
+		{File: "lib/internal/b.go", Package: "lib", InPackage: true, Label: "//lib:internal/b.go"},
+		{File: "README.md", Package: "", InPackage: true, Label: "//:README.md"},
+	}, owners)
+
+	_, err = LocalFileOwners(workspace, []string{"../outside.txt"})
+	assert.ErrorContains(t, err, "is not within workspace")
+	_, err = LocalFileOwners(workspace, []string{filepath.Join(filepath.Dir(workspace), "outside.txt")})
+	assert.ErrorContains(t, err, "is not within workspace")
+}
+
+func TestClientFileOwners(t *testing.T) {
+	workspace := writeWorkspace(t, "lib/BUILD", "lib/a.go", "lib/b.go", "app/BUILD", "app/main.go", "docs/notes.txt")
+	fake := bazeltest.New(t, bazeltest.Rule{
+		Pattern: "^query --output=streamed_jsonproto --keep_going ",
+		Stdout: `{"type":"SOURCE_FILE","sourceFile":{"name":"//lib:a.go"}}
+{"type":"SOURCE_FILE","sourceFile":{"name":"//app:main.go"}}
+{"type":"RULE","rule":{"name":"//lib:lib","ruleClass":"go_library","attribute":[{"name":"srcs","type":"LABEL_LIST","stringListValue":["//lib:a.go","//lib:b.go"]}]}}
+{"type":"RULE","rule":{"name":"@@//lib:lib_test","ruleClass":"go_test","attribute":[{"name":"srcs","type":"LABEL_LIST","stringListValue":["@@//lib:a.go"]}]}}
+`,
+		Stderr:   "ERROR: no such target '//lib:gone.go'\n",
+		ExitCode: 3,
+	})
+	client := NewClient(workspace)
+	client.Binary = fake.Path
+
+	owners, err := client.FileOwners([]string{"lib/a.go", "app/main.go", "lib/gone.go", "docs/notes.txt"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []FileOwner{
+		{File: "lib/a.go", Package: "lib", InPackage: true, Label: "//lib:a.go", Owners: []string{"//lib:lib", "//lib:lib_test"}},
+		{File: "app/main.go", Package: "app", InPackage: true, Label: "//app:main.go"},
+		{File: "lib/gone.go", Package: "lib", InPackage: true},
+		{File: "docs/notes.txt"},
+	}, owners)
+
+	invocations := fake.Invocations()
+	require.Len(t, invocations, 1, "Expected a single query for all the files")
+	assert.Equal(t, `set(//lib:a.go //app:main.go //lib:gone.go) + attr(srcs, "(^|[\[ ])@{0,2}(//lib:a\.go|//app:main\.go|//lib:gone\.go)(,|\]|$)", //lib:all + //app:all)`,
+		invocations[0].Args[len(invocations[0].Args)-1])
+}
+
+func TestClientFileOwnersOutsidePackages(t *testing.T) {
+	fake := bazeltest.New(t)
+	client := NewClient(writeWorkspace(t, "docs/notes.txt"))
+	client.Binary = fake.Path
+
+	owners, err := client.FileOwners([]string{"docs/notes.txt"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []FileOwner{{File: "docs/notes.txt"}}, owners)
+	assert.Empty(t, fake.Invocations())
+}
@@ -0,0 +1,312 @@
+// Package query builds Bazel query expressions, see https://bazel.build/query/language.
+// Expressions render to query strings with the words quoted where the query language requires it,
+// i.e. for labels and file names with spaces or regular expressions with special characters.
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a query expression, String renders it for bazel query, cquery or aquery
+type Expr interface {
+	String() string
+}
+
+// word is a target pattern, label or other word of the query language
+type word string
+
+// call is a query function call: deps(//foo, 1)
+type call struct {
+	name string
+	args []Expr
+}
+
+// setOperation is an infix set operation: a + b - c
+type setOperation struct {
+	op       string
+	operands []Expr
+}
+
+// let binds a variable in an expression: let v = e1 in e2
+type let struct {
+	name  string
+	value Expr
+	body  Expr
+}
+
+// raw is an expression used as is
+type raw string
+
+// variable is a reference to a variable bound by let: $v
+type variable string
+
+// integer is an integer argument, i.e. a depth
+type integer int
+
+// keywords of the query language, they have to be quoted when used as words
+var keywords = map[string]bool{
+	"let":       true,
+	"in":        true,
+	"set":       true,
+	"union":     true,
+	"intersect": true,
+	"except":    true,
+}
+
+// Word returns a target pattern, label, file name or regular expression, quoted if needed.
+// It fails for words which cannot be quoted, see Quote.
+func Word(s string) (Expr, error) {
+	if _, err := Quote(s); err != nil {
+		return nil, err
+	}
+	return word(s), nil
+}
+
+// Words returns a word for each string, it fails if any of them cannot be quoted
+func Words(words ...string) ([]Expr, error) {
+	exprs := make([]Expr, 0, len(words))
+	for _, w := range words {
+		x, err := Word(w)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, x)
+	}
+	return exprs, nil
+}
+
+// Raw returns an expression used as is, i.e. a query string provided by a user
+func Raw(s string) Expr {
+	return raw(s)
+}
+
+// Var returns a reference to a variable bound by Let: $name
+func Var(name string) Expr {
+	return variable(name)
+}
+
+// Set returns the set of the given target patterns: set(a b c)
+func Set(words ...string) (Expr, error) {
+	return wordsCall("set", nil, words...)
+}
+
+// Deps returns the transitive dependencies of x, x included
+func Deps(x Expr) Expr {
+	return &call{name: "deps", args: []Expr{x}}
+}
+
+// DepsDepth returns the dependencies of x up to the given depth, 1 for the direct dependencies
+func DepsDepth(x Expr, depth int) Expr {
+	return &call{name: "deps", args: []Expr{x, integer(depth)}}
+}
+
+// RDeps returns the targets of universe depending transitively on x, x included
+func RDeps(universe, x Expr) Expr {
+	return &call{name: "rdeps", args: []Expr{universe, x}}
+}
+
+// RDepsDepth returns the targets of universe depending on x up to the given depth
+func RDepsDepth(universe, x Expr, depth int) Expr {
+	return &call{name: "rdeps", args: []Expr{universe, x, integer(depth)}}
+}
+
+// AllRDeps returns the targets depending on x within the universe set by --universe_scope
+func AllRDeps(x Expr) Expr {
+	return &call{name: "allrdeps", args: []Expr{x}}
+}
+
+// Kind returns the targets of x whose kind matches the regular expression, i.e. "go_.* rule"
+func Kind(pattern string, x Expr) (Expr, error) {
+	return wordsCall("kind", []Expr{x}, pattern)
+}
+
+// Attr returns the targets of x whose attribute value matches the regular expression
+func Attr(name, pattern string, x Expr) (Expr, error) {
+	return wordsCall("attr", []Expr{x}, name, pattern)
+}
+
+// Filter returns the targets of x whose label matches the regular expression
+func Filter(pattern string, x Expr) (Expr, error) {
+	return wordsCall("filter", []Expr{x}, pattern)
+}
+
+// Labels returns the targets referred to by an attribute of the targets of x
+func Labels(attr string, x Expr) (Expr, error) {
+	return wordsCall("labels", []Expr{x}, attr)
+}
+
+// Tests returns the test targets of x, test suites expanded
+func Tests(x Expr) Expr {
+	return &call{name: "tests", args: []Expr{x}}
+}
+
+// SomePath returns a path from a target of from to a target of to, empty if there is none
+func SomePath(from, to Expr) Expr {
+	return &call{name: "somepath", args: []Expr{from, to}}
+}
+
+// AllPaths returns all the targets on paths from a target of from to a target of to
+func AllPaths(from, to Expr) Expr {
+	return &call{name: "allpaths", args: []Expr{from, to}}
+}
+
+// Some returns a single target of x
+func Some(x Expr) Expr {
+	return &call{name: "some", args: []Expr{x}}
+}
+
+// Siblings returns the targets in the same packages as the targets of x
+func Siblings(x Expr) Expr {
+	return &call{name: "siblings", args: []Expr{x}}
+}
+
+// BuildFiles returns the BUILD and .bzl files the packages of x depend on
+func BuildFiles(x Expr) Expr {
+	return &call{name: "buildfiles", args: []Expr{x}}
+}
+
+// RBuildFiles returns the BUILD files depending on the given files, paths relative to the workspace
+func RBuildFiles(files ...string) (Expr, error) {
+	return wordsCall("rbuildfiles", nil, files...)
+}
+
+// LoadFiles returns the .bzl files the packages of x load
+func LoadFiles(x Expr) Expr {
+	return &call{name: "loadfiles", args: []Expr{x}}
+}
+
+// Visible returns the targets of x visible to all the targets of predicate
+func Visible(predicate, x Expr) Expr {
+	return &call{name: "visible", args: []Expr{predicate, x}}
+}
+
+// Union returns the targets in any of the expressions: a + b
+func Union(x ...Expr) Expr {
+	return &setOperation{op: "+", operands: x}
+}
+
+// Intersect returns the targets in all of the expressions: a ^ b
+func Intersect(x ...Expr) Expr {
+	return &setOperation{op: "^", operands: x}
+}
+
+// Except returns the targets of x which are not in any of the other expressions: x - a - b
+func Except(x Expr, others ...Expr) Expr {
+	return &setOperation{op: "-", operands: append([]Expr{x}, others...)}
+}
+
+// Let binds the value to a variable in body, see Var: let name = value in body
+// It fails if name is not a valid variable name: letters, digits and underscores, not starting with a digit.
+func Let(name string, value, body Expr) (Expr, error) {
+	if !isVariableName(name) {
+		return nil, fmt.Errorf("invalid query variable name: %q", name)
+	}
+	return &let{name: name, value: value, body: body}, nil
+}
+
+// wordsCall returns a call of the function with the words followed by the other arguments
+func wordsCall(name string, args []Expr, words ...string) (Expr, error) {
+	exprs, err := Words(words...)
+	if err != nil {
+		return nil, err
+	}
+	return &call{name: name, args: append(exprs, args...)}, nil
+}
+
+func (w word) String() string {
+	// Words are checked when they are created
+	quoted, _ := Quote(string(w))
+	return quoted
+}
+
+func (r raw) String() string {
+	return string(r)
+}
+
+func (v variable) String() string {
+	return "$" + string(v)
+}
+
+func (i integer) String() string {
+	return fmt.Sprint(int(i))
+}
+
+func (c *call) String() string {
+	args := make([]string, 0, len(c.args))
+	for _, arg := range c.args {
+		args = append(args, arg.String())
+	}
+	separator := ", "
+	if c.name == "set" {
+		separator = " "
+	}
+	return c.name + "(" + strings.Join(args, separator) + ")"
+}
+
+func (s *setOperation) String() string {
+	operands := make([]string, 0, len(s.operands))
+	for _, x := range s.operands {
+		operands = append(operands, operand(x))
+	}
+	return strings.Join(operands, " "+s.op+" ")
+}
+
+func (l *let) String() string {
+	return fmt.Sprintf("let %s = %s in %s", l.name, operand(l.value), l.body)
+}
+
+// operand returns an operand of an infix operation, parenthesized unless it is a word or a call,
+// so that the expression does not depend on operator precedence
+func operand(x Expr) string {
+	switch x.(type) {
+	case *setOperation, *let, raw:
+		return "(" + x.String() + ")"
+	}
+	return x.String()
+}
+
+// Quote returns the word as is if it only contains characters allowed in unquoted words,
+// otherwise it is put in double quotes, or single quotes if it contains double quotes.
+// The query language has no escapes, it fails for words containing both kinds of quotes.
+func Quote(s string) (string, error) {
+	if isPlainWord(s) {
+		return s, nil
+	}
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`, nil
+	}
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'", nil
+	}
+	return "", fmt.Errorf("word %s contains both single and double quotes, it cannot be quoted", s)
+}
+
+// isPlainWord returns true for words which can be written without quotes
+func isPlainWord(s string) bool {
+	// A leading $ would make it a variable
+	if s == "" || keywords[s] || s[0] == '-' || s[0] == '*' || s[0] == '$' {
+		return false
+	}
+	for _, c := range s {
+		isAlphanumeric := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+		if !isAlphanumeric && !strings.ContainsRune("*/@.-_:$~[]", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isVariableName returns true for names which can be bound by let and referred to with $name
+func isVariableName(s string) bool {
+	if s == "" || keywords[s] {
+		return false
+	}
+	for i, c := range s {
+		isLetter := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+		if !isLetter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
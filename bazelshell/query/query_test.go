@@ -0,0 +1,151 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// must returns the expression of a builder, the test words are valid
+func must(x Expr, err error) Expr {
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+func TestExprString(t *testing.T) {
+	var tests = []struct {
+		name     string
+		expr     Expr
+		expected string
+	}{
+		{
+			name:     "deps",
+			expr:     Deps(must(Word("//foo:bar"))),
+			expected: "deps(//foo:bar)",
+		},
+		{
+			name:     "deps with depth",
+			expr:     DepsDepth(must(Word("//foo/...")), 1),
+			expected: "deps(//foo/..., 1)",
+		},
+		{
+			name:     "rdeps",
+			expr:     RDepsDepth(must(Word("//...")), must(Set("//a", "b/c.go")), 2),
+			expected: "rdeps(//..., set(//a b/c.go), 2)",
+		},
+		{
+			name:     "kind regular expression is quoted",
+			expr:     must(Kind("go_(library|binary) rule", Deps(must(Word("//app"))))),
+			expected: `kind("go_(library|binary) rule", deps(//app))`,
+		},
+		{
+			name:     "attr",
+			expr:     must(Attr("tags", `\bmanual\b`, must(Word("//...")))),
+			expected: `attr(tags, "\bmanual\b", //...)`,
+		},
+		{
+			name:     "filter",
+			expr:     must(Filter(`\.go$`, must(Labels("srcs", must(Word("//lib")))))),
+			expected: `filter("\.go$", labels(srcs, //lib))`,
+		},
+		{
+			name:     "paths",
+			expr:     Union(SomePath(must(Word("//app")), must(Word("//lib"))), AllPaths(must(Word("//app")), must(Word("@dep//:x")))),
+			expected: "somepath(//app, //lib) + allpaths(//app, @dep//:x)",
+		},
+		{
+			name:     "nested set operations are parenthesized",
+			expr:     Except(must(Word("//...")), Intersect(must(Word("//a/...")), must(Word("//b/..."))), Tests(must(Word("//c/...")))),
+			expected: "//... - (//a/... ^ //b/...) - tests(//c/...)",
+		},
+		{
+			name:     "let",
+			expr:     must(Let("v", Deps(must(Word("//app"))), Union(Var("v"), RDeps(Var("v"), must(Word("//lib")))))),
+			expected: "let v = deps(//app) in $v + rdeps($v, //lib)",
+		},
+		{
+			name:     "let as operand",
+			expr:     Union(must(Let("v", must(Word("//a")), Var("v"))), must(Word("//b"))),
+			expected: "(let v = //a in $v) + //b",
+		},
+		{
+			name:     "raw",
+			expr:     Intersect(Raw("//... except //third_party/..."), must(Word("//a/..."))),
+			expected: "(//... except //third_party/...) ^ //a/...",
+		},
+		{
+			name:     "rbuildfiles",
+			expr:     must(RBuildFiles("rules/defs.bzl", "tools/my rules.bzl")),
+			expected: `rbuildfiles(rules/defs.bzl, "tools/my rules.bzl")`,
+		},
+		{
+			name:     "visible and siblings",
+			expr:     Visible(must(Word("//app")), Siblings(Some(must(Word("//lib/..."))))),
+			expected: "visible(//app, siblings(some(//lib/...)))",
+		},
+		{
+			name:     "build and load files",
+			expr:     Union(BuildFiles(must(Word("//app"))), LoadFiles(must(Word("//app"))), AllRDeps(must(Word("//lib")))),
+			expected: "buildfiles(//app) + loadfiles(//app) + allrdeps(//lib)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.expr.String())
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	var tests = []struct {
+		word     string
+		expected string
+	}{
+		{word: "//foo:bar", expected: "//foo:bar"},
+		{word: "@repo//foo/...:all", expected: "@repo//foo/...:all"},
+		{word: "a/b-c_d.e", expected: "a/b-c_d.e"},
+		{word: "dir with space/file.txt", expected: `"dir with space/file.txt"`},
+		{word: "//foo:a+b", expected: `"//foo:a+b"`},
+		{word: `//foo:say"hi"`, expected: `'//foo:say"hi"'`},
+		{word: "-foo", expected: `"-foo"`},
+		{word: "*", expected: `"*"`},
+		{word: "$v", expected: `"$v"`},
+		{word: "except", expected: `"except"`},
+		{word: "", expected: `""`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.word, func(t *testing.T) {
+			quoted, err := Quote(tc.word)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, quoted)
+		})
+	}
+}
+
+func TestUnquotableWords(t *testing.T) {
+	both := `//foo:it's "quoted"`
+
+	_, err := Quote(both)
+	assert.Error(t, err)
+	_, err = Word(both)
+	assert.Error(t, err)
+	_, err = Set("//a", both)
+	assert.Error(t, err)
+	_, err = Filter(both, must(Word("//...")))
+	assert.Error(t, err)
+}
+
+func TestLetInvalidName(t *testing.T) {
+	for _, name := range []string{"", "1v", "v-1", "v in $x", "$v", "let"} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Let(name, must(Word("//a")), Var("v"))
+			assert.Error(t, err)
+		})
+	}
+	_, err := Let("_v1", must(Word("//a")), Var("_v1"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,293 @@
+package bazel
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// QueryOutput is an --output mode of bazel query with a line based output
+type QueryOutput string
+
+// Output modes parsed by ParseQueryOutput
+const (
+	OutputLabel     QueryOutput = "label"
+	OutputLabelKind QueryOutput = "label_kind"
+	OutputPackage   QueryOutput = "package"
+	OutputLocation  QueryOutput = "location"
+	OutputBuild     QueryOutput = "build"
+	OutputMinRank   QueryOutput = "minrank"
+	OutputMaxRank   QueryOutput = "maxrank"
+)
+
+// QueryOrder is a value of --order_output
+type QueryOrder string
+
+// Orders of the query output, see https://bazel.build/query/language#result-ordering
+const (
+	OrderNo   QueryOrder = "no"
+	OrderDeps QueryOrder = "deps"
+	OrderAuto QueryOrder = "auto"
+	OrderFull QueryOrder = "full"
+)
+
+// QueryOptions are the common flags of bazel query, the zero value uses the defaults of Bazel
+type QueryOptions struct {
+	// Output defaults to OutputLabel
+	Output QueryOutput
+	// KeepGoing returns the targets found despite errors, the result is then Partial
+	KeepGoing bool
+	// Universe are the target patterns of --universe_scope
+	Universe []string
+	// NoImplicitDeps excludes the dependencies added by rules, i.e. toolchains
+	NoImplicitDeps bool
+	// NoHostDeps excludes the dependencies in the exec configuration, i.e. tools (--notool_deps, formerly --nohost_deps)
+	NoHostDeps bool
+	// OrderOutput defaults to OrderAuto
+	OrderOutput QueryOrder
+	// Flags are passed after the options
+	Flags []string
+}
+
+// Args returns the Bazel flags of the options
+func (o QueryOptions) Args() []string {
+	output := o.Output
+	if output == "" {
+		output = OutputLabel
+	}
+	args := []string{"--output=" + string(output)}
+	if o.KeepGoing {
+		args = append(args, "--keep_going")
+	}
+	if len(o.Universe) > 0 {
+		args = append(args, "--universe_scope="+strings.Join(o.Universe, ","))
+	}
+	if o.NoImplicitDeps {
+		args = append(args, "--noimplicit_deps")
+	}
+	if o.NoHostDeps {
+		args = append(args, "--notool_deps")
+	}
+	if o.OrderOutput != "" {
+		args = append(args, "--order_output="+string(o.OrderOutput))
+	}
+	return append(args, o.Flags...)
+}
+
+// LabelKind is a line of --output=label_kind
+type LabelKind struct {
+	Label string
+	// Kind is the rule class for rules (i.e. go_library) and the kind of other targets (i.e. "source file"),
+	// like Target.Kind
+	Kind string
+}
+
+// TargetLocation is a line of --output=location
+type TargetLocation struct {
+	LabelKind
+	// File is the absolute path of the BUILD file of rules and of source files
+	File   string
+	Line   int
+	Column int
+}
+
+// BuildRule is a rule of --output=build, as it would be written in its BUILD file once macros are expanded
+type BuildRule struct {
+	// Location is where the rule is instantiated in the form /path/to/BUILD:line:column
+	Location string
+	Rule     *build.Rule
+}
+
+// RankedLabel is a line of --output=minrank and --output=maxrank
+type RankedLabel struct {
+	Rank  int
+	Label string
+}
+
+// ParsedQuery is the output of a query parsed according to its output mode, only the field of the mode is set
+type ParsedQuery struct {
+	Output QueryOutput
+	// Partial is true when errors were encountered with KeepGoing, see QueryResult.Partial
+	Partial bool
+
+	Labels     []string
+	LabelKinds []LabelKind
+	Packages   []string
+	Locations  []TargetLocation
+	Rules      []BuildRule
+	Ranks      []RankedLabel
+}
+
+// QueryWithOptions performs a Bazel query and parses its output according to opts.Output
+func (c *Client) QueryWithOptions(query string, opts QueryOptions) (*ParsedQuery, error) {
+	if opts.Output == "" {
+		opts.Output = OutputLabel
+	}
+	result, err := c.RunQuery("query", query, opts.Args())
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := ParseQueryOutput(opts.Output, bytes.NewReader(result.Stdout))
+	if err != nil {
+		return nil, err
+	}
+	parsed.Partial = result.Partial
+	return parsed, nil
+}
+
+// ParseQueryOutput parses the output of a query with the given output mode
+func ParseQueryOutput(output QueryOutput, r io.Reader) (*ParsedQuery, error) {
+	parsed := &ParsedQuery{Output: output}
+	var err error
+	switch output {
+	case OutputLabel:
+		parsed.Labels, err = ParseLabelOutput(r)
+	case OutputLabelKind:
+		parsed.LabelKinds, err = ParseLabelKindOutput(r)
+	case OutputPackage:
+		parsed.Packages, err = ParseLabelOutput(r)
+	case OutputLocation:
+		parsed.Locations, err = ParseLocationOutput(r)
+	case OutputBuild:
+		parsed.Rules, err = ParseBuildOutput(r)
+	case OutputMinRank, OutputMaxRank:
+		parsed.Ranks, err = ParseRankOutput(r)
+	default:
+		return nil, fmt.Errorf("unsupported query output %q", output)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// forEachLine calls parse with each non empty line, trimmed
+func forEachLine(r io.Reader, parse func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading query output: %w", err)
+	}
+	return nil
+}
+
+// ParseLabelOutput parses the output of --output=label and --output=package, one label or package per line
+func ParseLabelOutput(r io.Reader) ([]string, error) {
+	var labels []string
+	err := forEachLine(r, func(line string) error {
+		labels = append(labels, line)
+		return nil
+	})
+	return labels, err
+}
+
+// ParseLabelKindOutput parses the output of --output=label_kind, i.e. "go_library rule //lib:lib"
+func ParseLabelKindOutput(r io.Reader) ([]LabelKind, error) {
+	var targets []LabelKind
+	err := forEachLine(r, func(line string) error {
+		target, err := parseLabelKind(line)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target)
+		return nil
+	})
+	return targets, err
+}
+
+// parseLabelKind splits the kind and the label at the start of the label: labels may contain spaces,
+// i.e. "source file //pkg:my file.txt", while kinds never contain // or @
+func parseLabelKind(s string) (LabelKind, error) {
+	idx := -1
+	for _, start := range []string{" //", " @"} {
+		if i := strings.Index(s, start); i >= 0 && (idx < 0 || i < idx) {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return LabelKind{}, fmt.Errorf("invalid label_kind line %q", s)
+	}
+	kind := strings.TrimSuffix(strings.TrimSpace(s[:idx]), " rule")
+	return LabelKind{Label: s[idx+1:], Kind: kind}, nil
+}
+
+// locationLine matches a line of --output=location: /path/to/BUILD:3:11: go_library rule //lib:lib
+var locationLine = regexp.MustCompile(`^(.*?):(\d+)(?::(\d+))?: (.+)$`)
+
+// ParseLocationOutput parses the output of --output=location
+func ParseLocationOutput(r io.Reader) ([]TargetLocation, error) {
+	var locations []TargetLocation
+	err := forEachLine(r, func(line string) error {
+		match := locationLine.FindStringSubmatch(line)
+		if match == nil {
+			return fmt.Errorf("invalid location line %q", line)
+		}
+		target, err := parseLabelKind(match[4])
+		if err != nil {
+			return err
+		}
+		location := TargetLocation{LabelKind: target, File: match[1]}
+		location.Line, _ = strconv.Atoi(match[2])
+		location.Column, _ = strconv.Atoi(match[3])
+		locations = append(locations, location)
+		return nil
+	})
+	return locations, err
+}
+
+// buildLocationComment matches the comment preceding each rule of --output=build: # /path/to/BUILD:3:11
+var buildLocationComment = regexp.MustCompile(`^#\s*(\S.*:\d+:\d+)$`)
+
+// ParseBuildOutput parses the output of --output=build, the rules with the location they are instantiated at
+func ParseBuildOutput(r io.Reader) ([]BuildRule, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading query output: %w", err)
+	}
+	f, err := build.Parse("BUILD", data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing query output: %w", err)
+	}
+	var rules []BuildRule
+	for _, rule := range f.Rules("") {
+		buildRule := BuildRule{Rule: rule}
+		// The comments before a rule also hold the call stack of the previous one
+		for _, comment := range rule.Call.Comment().Before {
+			if match := buildLocationComment.FindStringSubmatch(comment.Token); match != nil {
+				buildRule.Location = match[1]
+			}
+		}
+		rules = append(rules, buildRule)
+	}
+	return rules, nil
+}
+
+// ParseRankOutput parses the output of --output=minrank and --output=maxrank, i.e. "0 //lib:lib"
+func ParseRankOutput(r io.Reader) ([]RankedLabel, error) {
+	var ranks []RankedLabel
+	err := forEachLine(r, func(line string) error {
+		rank, label, ok := strings.Cut(line, " ")
+		value, err := strconv.Atoi(rank)
+		if !ok || err != nil {
+			return fmt.Errorf("invalid rank line %q", line)
+		}
+		ranks = append(ranks, RankedLabel{Rank: value, Label: strings.TrimSpace(label)})
+		return nil
+	})
+	return ranks, err
+}
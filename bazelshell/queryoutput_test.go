@@ -0,0 +1,163 @@
+package bazel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/bazelshell/bazeltest"
+)
+
+func TestQueryOptionsArgs(t *testing.T) {
+	var tests = []struct {
+		name     string
+		options  QueryOptions
+		expected []string
+	}{
+		{name: "defaults", expected: []string{"--output=label"}},
+		{
+			name: "all options",
+			options: QueryOptions{
+				Output:         OutputLabelKind,
+				KeepGoing:      true,
+				Universe:       []string{"//...", "-//third_party/..."},
+				NoImplicitDeps: true,
+				NoHostDeps:     true,
+				OrderOutput:    OrderNo,
+				Flags:          []string{"--config=ci"},
+			},
+			expected: []string{
+				"--output=label_kind",
+				"--keep_going",
+				"--universe_scope=//...,-//third_party/...",
+				"--noimplicit_deps",
+				"--notool_deps",
+				"--order_output=no",
+				"--config=ci",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.options.Args())
+		})
+	}
+}
+
+func TestParseQueryOutput(t *testing.T) {
+	var tests = []struct {
+		name     string
+		output   QueryOutput
+		stdout   string
+		expected *ParsedQuery
+	}{
+		{
+			name:     "label",
+			output:   OutputLabel,
+			stdout:   "//lib:lib\n@rules_go//go:def.bzl\n\n",
+			expected: &ParsedQuery{Output: OutputLabel, Labels: []string{"//lib:lib", "@rules_go//go:def.bzl"}},
+		},
+		{
+			name:     "package",
+			output:   OutputPackage,
+			stdout:   "lib\n@com_github_pkg_errors//\n",
+			expected: &ParsedQuery{Output: OutputPackage, Packages: []string{"lib", "@com_github_pkg_errors//"}},
+		},
+		{
+			name:   "label_kind",
+			output: OutputLabelKind,
+			stdout: "go_library rule //lib:lib\nsource file //lib:a.go\ngenerated file //lib:gen.go\npackage group //:internal\n" +
+				"source file //lib:my file.txt\ngo_library rule @@rules_go~//go/tools:tool\n",
+			expected: &ParsedQuery{Output: OutputLabelKind, LabelKinds: []LabelKind{
+				{Label: "//lib:lib", Kind: "go_library"},
+				{Label: "//lib:a.go", Kind: "source file"},
+				{Label: "//lib:gen.go", Kind: "generated file"},
+				{Label: "//:internal", Kind: "package group"},
+				{Label: "//lib:my file.txt", Kind: "source file"},
+				{Label: "@@rules_go~//go/tools:tool", Kind: "go_library"},
+			}},
+		},
+		{
+			name:   "location",
+			output: OutputLocation,
+			stdout: "/home/user/workspace/lib/BUILD.bazel:3:11: go_library rule //lib:lib\n" +
+				"C:/workspace/lib/a.go:1:1: source file //lib:a.go\n" +
+				"/home/user/workspace/lib/my file.txt:1:1: source file //lib:my file.txt\n",
+			expected: &ParsedQuery{Output: OutputLocation, Locations: []TargetLocation{
+				{LabelKind: LabelKind{Label: "//lib:lib", Kind: "go_library"}, File: "/home/user/workspace/lib/BUILD.bazel", Line: 3, Column: 11},
+				{LabelKind: LabelKind{Label: "//lib:a.go", Kind: "source file"}, File: "C:/workspace/lib/a.go", Line: 1, Column: 1},
+				{LabelKind: LabelKind{Label: "//lib:my file.txt", Kind: "source file"}, File: "/home/user/workspace/lib/my file.txt", Line: 1, Column: 1},
+			}},
+		},
+		{
+			name:   "minrank",
+			output: OutputMinRank,
+			stdout: "0 //app:app\n1 //lib:lib\n2 @com_github_pkg_errors//:errors\n",
+			expected: &ParsedQuery{Output: OutputMinRank, Ranks: []RankedLabel{
+				{Rank: 0, Label: "//app:app"},
+				{Rank: 1, Label: "//lib:lib"},
+				{Rank: 2, Label: "@com_github_pkg_errors//:errors"},
+			}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, err := ParseQueryOutput(tc.output, strings.NewReader(tc.stdout))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, parsed)
+		})
+	}
+}
+
+func TestParseQueryOutputErrors(t *testing.T) {
+	var tests = []struct {
+		output      QueryOutput
+		stdout      string
+		expectedErr string
+	}{
+		{output: OutputLabelKind, stdout: "//lib:lib\n", expectedErr: `invalid label_kind line "//lib:lib"`},
+		{output: OutputLocation, stdout: "go_library rule //lib:lib\n", expectedErr: "invalid location line"},
+		{output: OutputMaxRank, stdout: "//lib:lib\n", expectedErr: `invalid rank line "//lib:lib"`},
+		{output: OutputBuild, stdout: "go_library(\n", expectedErr: "error parsing query output"},
+		{output: "proto", expectedErr: `unsupported query output "proto"`},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.output), func(t *testing.T) {
+			_, err := ParseQueryOutput(tc.output, strings.NewReader(tc.stdout))
+			assert.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestParseBuildOutput(t *testing.T) {
+	rules, err := ParseBuildOutput(openTestFile(t, "query.build"))
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	assert.Equal(t, "/home/user/workspace/lib/BUILD.bazel:3:11", rules[0].Location)
+	assert.Equal(t, "go_library", rules[0].Rule.Kind())
+	assert.Equal(t, "lib", rules[0].Rule.Name())
+	assert.Equal(t, []string{"@com_github_pkg_errors//:errors"}, rules[0].Rule.AttrStrings("deps"))
+
+	assert.Equal(t, "/home/user/workspace/lib/BUILD.bazel:12:8", rules[1].Location, "Expected the call stack of the previous rule to be skipped")
+	assert.Equal(t, "lib_test", rules[1].Rule.Name())
+	assert.Equal(t, "small", rules[1].Rule.AttrString("size"))
+}
+
+func TestClientQueryWithOptions(t *testing.T) {
+	fake := bazeltest.New(t, bazeltest.Rule{
+		Pattern:  "^query --output=label_kind --keep_going --noimplicit_deps deps\\(//lib\\)$",
+		Stdout:   "go_library rule //lib:lib\nsource file //lib:a.go\n",
+		Stderr:   "ERROR: /workspace/broken/BUILD:1:1: no such package\n",
+		ExitCode: 3,
+	})
+	client := NewClient(t.TempDir())
+	client.Binary = fake.Path
+
+	parsed, err := client.QueryWithOptions("deps(//lib)", QueryOptions{Output: OutputLabelKind, KeepGoing: true, NoImplicitDeps: true})
+	require.NoError(t, err)
+	assert.True(t, parsed.Partial)
+	assert.Equal(t, []LabelKind{{Label: "//lib:lib", Kind: "go_library"}, {Label: "//lib:a.go", Kind: "source file"}}, parsed.LabelKinds)
+}
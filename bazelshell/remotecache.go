@@ -0,0 +1,90 @@
+package bazel
+
+import (
+	"sort"
+)
+
+// cacheCommands are the commands executing actions, which accept the cache flags
+var cacheCommands = map[string]bool{"build": true, "test": true, "run": true, "coverage": true}
+
+// CacheOptions configures the remote and disk caches of builds and tests
+type CacheOptions struct {
+	// RemoteCache is the URL of the remote cache, i.e. grpcs://cache.example.com or http://localhost:8080
+	RemoteCache string
+	// DiskCache is the directory of the local disk cache
+	DiskCache string
+	// InstanceName is passed to the remote cache to separate the caches of different setups
+	InstanceName string
+	// Headers are sent to the remote cache with each request, i.e. for authentication
+	Headers map[string]string
+	// NoUpload disables uploading local results to the remote cache, they are only read from it
+	NoUpload bool
+}
+
+// Flags returns the Bazel flags for the options, headers sorted by name
+func (o *CacheOptions) Flags() []string {
+	var flags []string
+	if o.RemoteCache != "" {
+		flags = append(flags, "--remote_cache="+o.RemoteCache)
+	}
+	if o.DiskCache != "" {
+		flags = append(flags, "--disk_cache="+o.DiskCache)
+	}
+	if o.InstanceName != "" {
+		flags = append(flags, "--remote_instance_name="+o.InstanceName)
+	}
+	names := make([]string, 0, len(o.Headers))
+	for name := range o.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flags = append(flags, "--remote_header="+name+"="+o.Headers[name])
+	}
+	if o.NoUpload {
+		flags = append(flags, "--noremote_upload_local_results")
+	}
+	return flags
+}
+
+// Runners of the buildMetrics event which are not executing actions
+const (
+	runnerTotal          = "total"
+	runnerInternal       = "internal"
+	runnerRemoteCacheHit = "remote cache hit"
+	runnerDiskCacheHit   = "disk cache hit"
+)
+
+// CacheStats counts the actions served from the remote and disk caches and the ones executed
+type CacheStats struct {
+	RemoteHits int
+	DiskHits   int
+	// Misses are the actions executed locally or remotely, internal actions excluded
+	Misses int
+}
+
+// CacheStats returns the remote and disk cache hits and misses of the build
+func (m *BuildMetrics) CacheStats() CacheStats {
+	var stats CacheStats
+	for runner, count := range m.Runners {
+		switch runner {
+		case runnerTotal, runnerInternal:
+		case runnerRemoteCacheHit:
+			stats.RemoteHits += count
+		case runnerDiskCacheHit:
+			stats.DiskHits += count
+		default:
+			stats.Misses += count
+		}
+	}
+	return stats
+}
+
+// HitRate returns the share of the actions served from a cache, between 0 and 1, 0 if no action ran
+func (s CacheStats) HitRate() float64 {
+	total := s.RemoteHits + s.DiskHits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.RemoteHits+s.DiskHits) / float64(total)
+}
@@ -0,0 +1,71 @@
+package bazel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/bazelshell/bazeltest"
+)
+
+func TestCacheOptionsFlags(t *testing.T) {
+	var tests = []struct {
+		name     string
+		options  CacheOptions
+		expected []string
+	}{
+		{name: "empty"},
+		{
+			name:     "disk cache",
+			options:  CacheOptions{DiskCache: "/tmp/cache"},
+			expected: []string{"--disk_cache=/tmp/cache"},
+		},
+		{
+			name: "remote cache",
+			options: CacheOptions{
+				RemoteCache:  "grpcs://cache.example.com",
+				InstanceName: "ci",
+				Headers:      map[string]string{"x-token": "secret", "Authorization": "Bearer token"},
+				NoUpload:     true,
+			},
+			expected: []string{
+				"--remote_cache=grpcs://cache.example.com",
+				"--remote_instance_name=ci",
+				"--remote_header=Authorization=Bearer token",
+				"--remote_header=x-token=secret",
+				"--noremote_upload_local_results",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.options.Flags())
+		})
+	}
+}
+
+func TestClientCacheOptions(t *testing.T) {
+	fake := bazeltest.New(t,
+		bazeltest.Rule{Pattern: "^build --remote_cache=http://localhost:8080 --disk_cache=/tmp/cache --config=ci -- //...$"},
+		bazeltest.Rule{Pattern: "^query //...$"},
+	)
+	client := NewClient(t.TempDir())
+	client.Binary = fake.Path
+	client.Cache = &CacheOptions{RemoteCache: "http://localhost:8080", DiskCache: "/tmp/cache"}
+
+	_, err := client.Build([]string{"//..."}, []string{"--config=ci"})
+	require.NoError(t, err)
+	_, err = client.Query("//...", nil)
+	require.NoError(t, err, "Expected query not to be given the cache flags")
+}
+
+func TestBuildMetricsCacheStats(t *testing.T) {
+	events, err := ParseBuildEvents(openTestFile(t, "bep_metrics.json"))
+	require.NoError(t, err)
+	stats := SummarizeBuildEvents(events).Metrics.CacheStats()
+
+	assert.Equal(t, CacheStats{RemoteHits: 6, DiskHits: 1, Misses: 3}, stats)
+	assert.InDelta(t, 0.7, stats.HitRate(), 1e-9)
+	assert.Zero(t, CacheStats{}.HitRate())
+}
@@ -0,0 +1,162 @@
+package bazel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// TargetType is the kind of target returned by a query
+type TargetType string
+
+const (
+	// RuleTarget is a target instantiated by a rule, i.e. a go_library
+	RuleTarget TargetType = "RULE"
+	// SourceFileTarget is a file checked into the workspace
+	SourceFileTarget TargetType = "SOURCE_FILE"
+	// GeneratedFileTarget is a file produced by a rule
+	GeneratedFileTarget TargetType = "GENERATED_FILE"
+	// PackageGroupTarget is a package_group
+	PackageGroupTarget TargetType = "PACKAGE_GROUP"
+	// EnvironmentGroupTarget is an environment_group
+	EnvironmentGroupTarget TargetType = "ENVIRONMENT_GROUP"
+)
+
+// Target is a single target as returned by QueryTargets.
+// It mirrors the Target message of Bazel's build.proto, flattened for the different target types.
+// see https://github.com/bazelbuild/bazel/blob/master/src/main/protobuf/build.proto
+type Target struct {
+	Type  TargetType
+	Label string
+	// Kind is the rule class for rules (i.e. go_library) and the same kind as reported by
+	// --output=label_kind for other targets (i.e. "source file")
+	Kind string
+	// Location is the place the target is defined at in the form /path/to/BUILD:line:column
+	Location string
+	// Attributes of a rule, in the order Bazel reports them
+	Attributes []Attribute
+	// RuleInputs and RuleOutputs are the labels of the direct inputs and outputs of a rule
+	RuleInputs  []string
+	RuleOutputs []string
+	// GeneratingRule is the label of the rule producing a generated file
+	GeneratingRule string
+	// ContainedPackages are the package specifications of a package group
+	ContainedPackages []string
+}
+
+// Attribute is a single attribute of a rule target.
+// Only the value field matching the Type is set; label values are reported as strings.
+type Attribute struct {
+	Name                string     `json:"name"`
+	Type                string     `json:"type"`
+	ExplicitlySpecified bool       `json:"explicitlySpecified"`
+	StringValue         string     `json:"stringValue"`
+	IntValue            int        `json:"intValue"`
+	BooleanValue        bool       `json:"booleanValue"`
+	TristateValue       string     `json:"tristateValue"`
+	StringListValue     []string   `json:"stringListValue"`
+	IntListValue        []int      `json:"intListValue"`
+	StringDictValue     []KeyValue `json:"stringDictValue"`
+}
+
+// KeyValue is a single entry of a dictionary attribute
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Attr returns the attribute with the given name, if the target has it
+func (t *Target) Attr(name string) (Attribute, bool) {
+	for _, attr := range t.Attributes {
+		if attr.Name == name {
+			return attr, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// QueryTargets performs a Bazel query and returns the matching targets with their kind,
+// location and attributes, using --output=streamed_jsonproto (Bazel 6.3 and later).
+func QueryTargets(folder, query string, flags []string) ([]Target, error) {
+	return NewClient(folder).QueryTargets(query, flags)
+}
+
+type jsonTarget struct {
+	Type string `json:"type"`
+	Rule *struct {
+		Name       string      `json:"name"`
+		RuleClass  string      `json:"ruleClass"`
+		Location   string      `json:"location"`
+		Attribute  []Attribute `json:"attribute"`
+		RuleInput  []string    `json:"ruleInput"`
+		RuleOutput []string    `json:"ruleOutput"`
+	} `json:"rule"`
+	SourceFile *struct {
+		Name     string `json:"name"`
+		Location string `json:"location"`
+	} `json:"sourceFile"`
+	GeneratedFile *struct {
+		Name           string `json:"name"`
+		GeneratingRule string `json:"generatingRule"`
+		Location       string `json:"location"`
+	} `json:"generatedFile"`
+	PackageGroup *struct {
+		Name             string   `json:"name"`
+		ContainedPackage []string `json:"containedPackage"`
+	} `json:"packageGroup"`
+	EnvironmentGroup *struct {
+		Name string `json:"name"`
+	} `json:"environmentGroup"`
+}
+
+// ParseStreamedJSONProto parses the output of a query with --output=streamed_jsonproto,
+// a stream of JSON encoded Target messages.
+func ParseStreamedJSONProto(r io.Reader) ([]Target, error) {
+	var targets []Target
+	decoder := json.NewDecoder(r)
+	for {
+		var raw jsonTarget
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return targets, nil
+		} else if err != nil {
+			return targets, fmt.Errorf("error decoding query output: %w", err)
+		}
+		target, err := raw.toTarget()
+		if err != nil {
+			return targets, err
+		}
+		targets = append(targets, target)
+	}
+}
+
+func (raw *jsonTarget) toTarget() (Target, error) {
+	target := Target{Type: TargetType(raw.Type)}
+	switch {
+	case raw.Rule != nil:
+		target.Label = raw.Rule.Name
+		target.Kind = raw.Rule.RuleClass
+		target.Location = raw.Rule.Location
+		target.Attributes = raw.Rule.Attribute
+		target.RuleInputs = raw.Rule.RuleInput
+		target.RuleOutputs = raw.Rule.RuleOutput
+	case raw.SourceFile != nil:
+		target.Label = raw.SourceFile.Name
+		target.Kind = "source file"
+		target.Location = raw.SourceFile.Location
+	case raw.GeneratedFile != nil:
+		target.Label = raw.GeneratedFile.Name
+		target.Kind = "generated file"
+		target.Location = raw.GeneratedFile.Location
+		target.GeneratingRule = raw.GeneratedFile.GeneratingRule
+	case raw.PackageGroup != nil:
+		target.Label = raw.PackageGroup.Name
+		target.Kind = "package group"
+		target.ContainedPackages = raw.PackageGroup.ContainedPackage
+	case raw.EnvironmentGroup != nil:
+		target.Label = raw.EnvironmentGroup.Name
+		target.Kind = "environment group"
+	default:
+		return target, fmt.Errorf("unsupported target type: %s", raw.Type)
+	}
+	return target, nil
+}
@@ -0,0 +1,68 @@
+package bazel
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestDir() string {
+	workDir, _ := os.Getwd()
+	return filepath.Join(workDir, "test-data")
+}
+
+func openTestFile(t *testing.T, name string) *os.File {
+	f, err := os.Open(filepath.Join(getTestDir(), name))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+func TestParseStreamedJSONProto(t *testing.T) {
+	targets, err := ParseStreamedJSONProto(openTestFile(t, "query.streamed_jsonproto"))
+	require.NoError(t, err)
+	require.Len(t, targets, 4)
+
+	rule := targets[0]
+	assert.Equal(t, RuleTarget, rule.Type)
+	assert.Equal(t, "//cmd/tool:tool_lib", rule.Label)
+	assert.Equal(t, "go_library", rule.Kind)
+	assert.Equal(t, "/workspace/cmd/tool/BUILD.bazel:3:11", rule.Location)
+	assert.Equal(t, []string{"//cmd/tool:tool_lib.a"}, rule.RuleOutputs)
+	assert.Len(t, rule.RuleInputs, 4)
+
+	srcs, found := rule.Attr("srcs")
+	assert.True(t, found)
+	assert.Equal(t, "LABEL_LIST", srcs.Type)
+	assert.True(t, srcs.ExplicitlySpecified)
+	assert.Equal(t, []string{"//cmd/tool:main.go", "//cmd/tool:flags.go"}, srcs.StringListValue)
+	importpath, _ := rule.Attr("importpath")
+	assert.Equal(t, "github.com/example/cmd/tool", importpath.StringValue)
+	stamp, _ := rule.Attr("stamp")
+	assert.Equal(t, "AUTO", stamp.TristateValue)
+	xDefs, _ := rule.Attr("x_defs")
+	assert.Equal(t, []KeyValue{{Key: "main.version", Value: "1.0"}}, xDefs.StringDictValue)
+	_, found = rule.Attr("does_not_exist")
+	assert.False(t, found)
+
+	assert.Equal(t, Target{Type: SourceFileTarget, Label: "//cmd/tool:main.go", Kind: "source file", Location: "/workspace/cmd/tool/main.go:1:1"}, targets[1])
+	assert.Equal(t, "//cmd/tool:tool_lib", targets[2].GeneratingRule)
+	assert.Equal(t, "generated file", targets[2].Kind)
+	assert.Equal(t, []string{"//cmd/...", "//tools"}, targets[3].ContainedPackages)
+}
+
+func TestParseStreamedJSONProtoErrors(t *testing.T) {
+	_, err := ParseStreamedJSONProto(strings.NewReader(`{"type":"RULE"`))
+	assert.Error(t, err, "Expected truncated output to be reported")
+
+	_, err = ParseStreamedJSONProto(strings.NewReader(`{"type":"SOMETHING_NEW"}`))
+	assert.Error(t, err, "Expected unknown target types to be reported")
+
+	empty, err := ParseStreamedJSONProto(strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
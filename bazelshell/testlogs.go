@@ -0,0 +1,316 @@
+package bazel
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TestAttempt holds the files written by a single attempt of a shard and run of a test target
+type TestAttempt struct {
+	// Shard and Run start at 1, they are 0 for tests which are not sharded or run once
+	Shard int
+	Run   int
+	// Attempt starts at 1, attempts are retried with --flaky_test_attempts
+	Attempt int
+	// Final is true for the last attempt, the one the result of the shard and run is taken from
+	Final bool
+	// XML and Log are the paths of the JUnit report and of the output of the test, empty if missing
+	XML string
+	Log string
+	// Outputs is the directory of the undeclared outputs (TEST_UNDECLARED_OUTPUTS_DIR), empty if missing
+	Outputs string
+}
+
+// TestLogs are the files written by the runs of a test target in the testlogs directory
+type TestLogs struct {
+	Label string
+	// Dir is the directory of the test target in the testlogs directory
+	Dir      string
+	Attempts []TestAttempt
+}
+
+// shardDirPattern and runDirPattern match the directories of sharded tests and of tests run several times,
+// shard_1_of_2_run_1_of_3 for both
+var (
+	shardDirPattern = regexp.MustCompile(`^shard_(\d+)_of_\d+(?:_run_(\d+)_of_\d+)?$`)
+	runDirPattern   = regexp.MustCompile(`^run_(\d+)_of_\d+$`)
+)
+
+// attemptPattern matches the files of the attempts before the last one, in the test_attempts directory
+var attemptPattern = regexp.MustCompile(`^attempt_(\d+)\.(xml|log|outputs)$`)
+
+// TestLogsDir returns the directory of the logs of a test target relative to the testlogs directory:
+// package/name, prefixed with external/repository for tests of other repositories
+func TestLogsDir(label string) (string, error) {
+	parsed, err := ParseLabel(label)
+	if err != nil {
+		return "", err
+	}
+	dir := path.Join(parsed.Package, parsed.Name)
+	if repo := parsed.RepoName(); repo != "" {
+		dir = path.Join("external", repo, dir)
+	}
+	return dir, nil
+}
+
+// FindTestLogs locates the files written by the last bazel test of a target in the testlogs directory
+// (bazel info bazel-testlogs): test.xml, test.log and test.outputs of each shard, run and attempt.
+func FindTestLogs(testlogsDir, label string) (*TestLogs, error) {
+	dir, err := TestLogsDir(label)
+	if err != nil {
+		return nil, err
+	}
+	logs := &TestLogs{Label: label, Dir: filepath.Join(testlogsDir, filepath.FromSlash(dir))}
+	entries, err := os.ReadDir(logs.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading test logs of %s: %w", label, err)
+	}
+	for _, entry := range entries {
+		shard, run, ok := parseRunDir(entry.Name())
+		if !entry.IsDir() || !ok {
+			continue
+		}
+		if logs.Attempts, err = appendAttempts(logs.Attempts, filepath.Join(logs.Dir, entry.Name()), shard, run); err != nil {
+			return nil, err
+		}
+	}
+	if len(logs.Attempts) == 0 {
+		if logs.Attempts, err = appendAttempts(nil, logs.Dir, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(logs.Attempts, func(i, j int) bool {
+		a, b := logs.Attempts[i], logs.Attempts[j]
+		if a.Shard != b.Shard {
+			return a.Shard < b.Shard
+		}
+		if a.Run != b.Run {
+			return a.Run < b.Run
+		}
+		return a.Attempt < b.Attempt
+	})
+	return logs, nil
+}
+
+// parseRunDir returns the shard and run of a directory of a sharded test or of a test run several times
+func parseRunDir(name string) (shard, run int, ok bool) {
+	if match := shardDirPattern.FindStringSubmatch(name); match != nil {
+		shard, _ = strconv.Atoi(match[1])
+		run, _ = strconv.Atoi(match[2])
+		return shard, run, true
+	}
+	if match := runDirPattern.FindStringSubmatch(name); match != nil {
+		run, _ = strconv.Atoi(match[1])
+		return 0, run, true
+	}
+	return 0, 0, false
+}
+
+// appendAttempts appends the attempts of a shard and run found in dir, the last one being test.xml and test.log
+func appendAttempts(attempts []TestAttempt, dir string, shard, run int) ([]TestAttempt, error) {
+	previous := map[int]*TestAttempt{}
+	entries, err := os.ReadDir(filepath.Join(dir, "test_attempts"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading test attempts in %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		match := attemptPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(match[1])
+		attempt, ok := previous[number]
+		if !ok {
+			attempt = &TestAttempt{Shard: shard, Run: run, Attempt: number}
+			previous[number] = attempt
+		}
+		file := filepath.Join(dir, "test_attempts", entry.Name())
+		switch match[2] {
+		case "xml":
+			attempt.XML = file
+		case "log":
+			attempt.Log = file
+		case "outputs":
+			attempt.Outputs = file
+		}
+	}
+	for _, attempt := range previous {
+		attempts = append(attempts, *attempt)
+	}
+	final := TestAttempt{Shard: shard, Run: run, Attempt: len(previous) + 1, Final: true}
+	final.XML = existingPath(filepath.Join(dir, "test.xml"))
+	final.Log = existingPath(filepath.Join(dir, "test.log"))
+	final.Outputs = existingPath(filepath.Join(dir, "test.outputs"))
+	if final.XML != "" || final.Log != "" {
+		attempts = append(attempts, final)
+	}
+	return attempts, nil
+}
+
+// existingPath returns the path if it exists, empty otherwise
+func existingPath(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// TestLogs locates the test logs of the given test targets, see FindTestLogs.
+// The flags are the ones of the bazel test command, the testlogs directory depends on the configuration.
+// Only the configuration flags are passed to bazel info (see configurationFlags): it rejects the test flags.
+func (c *Client) TestLogs(labels []string, flags []string) ([]*TestLogs, error) {
+	result, err := c.Run("info", append(configurationFlags(flags), "bazel-testlogs")...)
+	if err != nil {
+		return nil, err
+	}
+	testlogsDir := strings.TrimSpace(string(result.Stdout))
+	all := make([]*TestLogs, 0, len(labels))
+	for _, label := range labels {
+		logs, err := FindTestLogs(testlogsDir, label)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, logs)
+	}
+	return all, nil
+}
+
+// configurationValueFlags are the flags selecting the configuration, and with it the output directories
+var configurationValueFlags = map[string]bool{
+	"--config":           true,
+	"-c":                 true,
+	"--compilation_mode": true,
+	"--cpu":              true,
+	"--platforms":        true,
+}
+
+// configurationFlags returns the flags selecting the configuration: --config, -c, --compilation_mode, --cpu,
+// --platforms and Starlark flags (--//pkg:flag, --@repo//pkg:flag), in the --flag=value or --flag value forms
+func configurationFlags(flags []string) []string {
+	var kept []string
+	for i := 0; i < len(flags); i++ {
+		name, _, hasValue := strings.Cut(flags[i], "=")
+		switch {
+		case strings.HasPrefix(name, "--//") || strings.HasPrefix(name, "--@") || strings.HasPrefix(name, "--no//") ||
+			strings.HasPrefix(name, "--no@"):
+			kept = append(kept, flags[i])
+		case configurationValueFlags[name]:
+			kept = append(kept, flags[i])
+			if !hasValue && i+1 < len(flags) {
+				i++
+				kept = append(kept, flags[i])
+			}
+		}
+	}
+	return kept
+}
+
+// TestReport aggregates the JUnit reports of all the shards, runs and attempts of a test target
+type TestReport struct {
+	Label string
+	// Status is TestPassed, TestFailed or TestFlaky, TestNoStatus if no final attempt wrote a report
+	Status TestStatus
+	Cases  []TestCaseResult
+	// Passed, Failed, Skipped and Flaky count the cases by status, errors are counted as failures
+	Passed  int
+	Failed  int
+	Skipped int
+	Flaky   int
+	// Duration is the total duration of the suites of the final attempts
+	Duration time.Duration
+}
+
+// TestCaseResult is a test case of a shard and run, aggregated over its attempts
+type TestCaseResult struct {
+	// TestCase is the case as reported by the final attempt, its Status is TestCaseFlaky
+	// if it passed after failing in previous attempts
+	TestCase
+	Suite string
+	Shard int
+	Run   int
+	// Attempts is the number of attempts which reported the case
+	Attempts int
+	// Failures are the failures of all the attempts, in order
+	Failures []TestFailure
+}
+
+// Report parses the JUnit reports of all the attempts and aggregates them by test case
+func (l *TestLogs) Report() (*TestReport, error) {
+	report := &TestReport{Label: l.Label, Status: TestNoStatus}
+	type caseKey struct {
+		shard, run             int
+		suite, className, name string
+	}
+	index := map[caseKey]int{}
+	hasFinal := false
+	for _, attempt := range l.Attempts {
+		if attempt.XML == "" {
+			continue
+		}
+		f, err := os.Open(attempt.XML)
+		if err != nil {
+			return nil, fmt.Errorf("error reading test report of %s: %w", l.Label, err)
+		}
+		suites, err := ParseJUnitXML(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", attempt.XML, err)
+		}
+		hasFinal = hasFinal || attempt.Final
+		for _, suite := range suites {
+			if attempt.Final {
+				report.Duration += suite.Duration
+			}
+			for _, testCase := range suite.Cases {
+				key := caseKey{attempt.Shard, attempt.Run, suite.Name, testCase.ClassName, testCase.Name}
+				i, ok := index[key]
+				if !ok {
+					i = len(report.Cases)
+					index[key] = i
+					report.Cases = append(report.Cases, TestCaseResult{Suite: suite.Name, Shard: attempt.Shard, Run: attempt.Run})
+				}
+				result := &report.Cases[i]
+				result.Attempts++
+				if testCase.Failure != nil {
+					result.Failures = append(result.Failures, *testCase.Failure)
+				}
+				failedBefore := len(result.Failures) > 0 && testCase.Failure == nil
+				result.TestCase = testCase
+				if testCase.Status == TestCasePassed && failedBefore {
+					result.Status = TestCaseFlaky
+				}
+			}
+		}
+	}
+	if !hasFinal {
+		return report, nil
+	}
+	for _, result := range report.Cases {
+		switch result.Status {
+		case TestCasePassed:
+			report.Passed++
+		case TestCaseFailed, TestCaseError:
+			report.Failed++
+		case TestCaseSkipped:
+			report.Skipped++
+		case TestCaseFlaky:
+			report.Flaky++
+		}
+	}
+	switch {
+	case report.Failed > 0:
+		report.Status = TestFailed
+	case report.Flaky > 0:
+		report.Status = TestFlaky
+	default:
+		report.Status = TestPassed
+	}
+	return report, nil
+}
@@ -0,0 +1,222 @@
+package bazel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/bazelshell/bazeltest"
+)
+
+// writeTestLogs writes files with the given content in a temporary testlogs directory,
+// paths ending with / are created as directories
+func writeTestLogs(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for file, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if file[len(file)-1] == '/' {
+			require.NoError(t, os.MkdirAll(path, 0755))
+			continue
+		}
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+// junitReport returns a JUnit report with a passed case a and case b failed or passed
+func junitReport(bFailed bool) string {
+	b := `<testcase name="b" time="0.5"/>`
+	if bFailed {
+		b = `<testcase name="b" time="0.5"><failure message="boom"/></testcase>`
+	}
+	return `<testsuites><testsuite name="suite" time="1"><testcase name="a" time="0.5"/>` + b + `</testsuite></testsuites>`
+}
+
+func TestTestLogsDir(t *testing.T) {
+	var tests = []struct {
+		label    string
+		expected string
+	}{
+		{label: "//lib:lib_test", expected: "lib/lib_test"},
+		{label: "//lib/sub", expected: "lib/sub/sub"},
+		{label: "@rules_go//go/tools:test", expected: "external/rules_go/go/tools/test"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.label, func(t *testing.T) {
+			dir, err := TestLogsDir(tc.label)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, dir)
+		})
+	}
+}
+
+func TestFindTestLogs(t *testing.T) {
+	testlogs := writeTestLogs(t, map[string]string{
+		"single/test/test.xml":                             "",
+		"single/test/test.log":                             "",
+		"single/test/test.outputs/":                        "",
+		"flaky/test/test_attempts/attempt_1.xml":           "",
+		"flaky/test/test_attempts/attempt_1.log":           "",
+		"flaky/test/test_attempts/attempt_2.log":           "",
+		"flaky/test/test.xml":                              "",
+		"flaky/test/test.log":                              "",
+		"sharded/test/shard_2_of_2/test.log":               "",
+		"sharded/test/shard_1_of_2/test.log":               "",
+		"sharded/test/shard_1_of_2_run_2_of_2/test.log":    "",
+		"runs/test/run_1_of_2/test.log":                    "",
+		"runs/test/run_2_of_2/test.log":                    "",
+		"runs/test/run_2_of_2/test_attempts/attempt_1.log": "",
+	})
+	dir := func(rel string) string { return filepath.Join(testlogs, filepath.FromSlash(rel)) }
+
+	var tests = []struct {
+		label    string
+		expected []TestAttempt
+	}{
+		{
+			label: "//single:test",
+			expected: []TestAttempt{
+				{Attempt: 1, Final: true, XML: dir("single/test/test.xml"), Log: dir("single/test/test.log"), Outputs: dir("single/test/test.outputs")},
+			},
+		},
+		{
+			label: "//flaky:test",
+			expected: []TestAttempt{
+				{Attempt: 1, XML: dir("flaky/test/test_attempts/attempt_1.xml"), Log: dir("flaky/test/test_attempts/attempt_1.log")},
+				{Attempt: 2, Log: dir("flaky/test/test_attempts/attempt_2.log")},
+				{Attempt: 3, Final: true, XML: dir("flaky/test/test.xml"), Log: dir("flaky/test/test.log")},
+			},
+		},
+		{
+			label: "//sharded:test",
+			expected: []TestAttempt{
+				{Shard: 1, Attempt: 1, Final: true, Log: dir("sharded/test/shard_1_of_2/test.log")},
+				{Shard: 1, Run: 2, Attempt: 1, Final: true, Log: dir("sharded/test/shard_1_of_2_run_2_of_2/test.log")},
+				{Shard: 2, Attempt: 1, Final: true, Log: dir("sharded/test/shard_2_of_2/test.log")},
+			},
+		},
+		{
+			label: "//runs:test",
+			expected: []TestAttempt{
+				{Run: 1, Attempt: 1, Final: true, Log: dir("runs/test/run_1_of_2/test.log")},
+				{Run: 2, Attempt: 1, Log: dir("runs/test/run_2_of_2/test_attempts/attempt_1.log")},
+				{Run: 2, Attempt: 2, Final: true, Log: dir("runs/test/run_2_of_2/test.log")},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.label, func(t *testing.T) {
+			logs, err := FindTestLogs(testlogs, tc.label)
+			require.NoError(t, err)
+			assert.Equal(t, tc.label, logs.Label)
+			assert.Equal(t, tc.expected, logs.Attempts)
+		})
+	}
+
+	_, err := FindTestLogs(testlogs, "//missing:test")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestTestLogsReport(t *testing.T) {
+	var tests = []struct {
+		name             string
+		files            map[string]string
+		expectedStatus   TestStatus
+		expectedCounts   [4]int
+		expectedDuration time.Duration
+	}{
+		{
+			name:             "passed",
+			files:            map[string]string{"lib/test/test.xml": junitReport(false)},
+			expectedStatus:   TestPassed,
+			expectedCounts:   [4]int{2, 0, 0, 0},
+			expectedDuration: time.Second,
+		},
+		{
+			name: "flaky",
+			files: map[string]string{
+				"lib/test/test_attempts/attempt_1.xml": junitReport(true),
+				"lib/test/test.xml":                    junitReport(false),
+			},
+			expectedStatus:   TestFlaky,
+			expectedCounts:   [4]int{1, 0, 0, 1},
+			expectedDuration: time.Second,
+		},
+		{
+			name: "failed in one shard",
+			files: map[string]string{
+				"lib/test/shard_1_of_2/test.xml": junitReport(false),
+				"lib/test/shard_2_of_2/test.xml": junitReport(true),
+			},
+			expectedStatus:   TestFailed,
+			expectedCounts:   [4]int{3, 1, 0, 0},
+			expectedDuration: 2 * time.Second,
+		},
+		{
+			name:           "no report",
+			files:          map[string]string{"lib/test/test.log": "crashed"},
+			expectedStatus: TestNoStatus,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logs, err := FindTestLogs(writeTestLogs(t, tc.files), "//lib:test")
+			require.NoError(t, err)
+			report, err := logs.Report()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, report.Status)
+			assert.Equal(t, tc.expectedCounts, [4]int{report.Passed, report.Failed, report.Skipped, report.Flaky})
+			assert.Equal(t, tc.expectedDuration, report.Duration)
+		})
+	}
+}
+
+func TestTestLogsReportFlakyCase(t *testing.T) {
+	logs, err := FindTestLogs(writeTestLogs(t, map[string]string{
+		"lib/test/test_attempts/attempt_1.xml": junitReport(true),
+		"lib/test/test.xml":                    junitReport(false),
+	}), "//lib:test")
+	require.NoError(t, err)
+	report, err := logs.Report()
+	require.NoError(t, err)
+
+	require.Len(t, report.Cases, 2)
+	b := report.Cases[1]
+	assert.Equal(t, "b", b.Name)
+	assert.Equal(t, "suite", b.Suite)
+	assert.Equal(t, TestCaseFlaky, b.Status)
+	assert.Equal(t, 2, b.Attempts)
+	assert.Equal(t, []TestFailure{{Message: "boom"}}, b.Failures)
+}
+
+func TestClientTestLogs(t *testing.T) {
+	testlogs := writeTestLogs(t, map[string]string{"lib/test/test.log": ""})
+	fake := bazeltest.New(t, bazeltest.Rule{
+		Pattern: "^info --config=ci bazel-testlogs$",
+		Stdout:  testlogs + "\n",
+	})
+	client := NewClient(t.TempDir())
+	client.Binary = fake.Path
+
+	logs, err := client.TestLogs([]string{"//lib:test"}, []string{"--config=ci", "--test_output=errors", "--flaky_test_attempts", "3"})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, filepath.Join(testlogs, "lib", "test"), logs[0].Dir)
+}
+
+func TestConfigurationFlags(t *testing.T) {
+	flags := []string{
+		"--config=ci", "-c", "opt", "--test_output=errors", "--flaky_test_attempts", "3", "--platforms=//:linux",
+		"--//tools:flag=value", "--no@rules_go//go/config:race", "--keep_going", "--compilation_mode", "dbg",
+	}
+	assert.Equal(t, []string{
+		"--config=ci", "-c", "opt", "--platforms=//:linux", "--//tools:flag=value", "--no@rules_go//go/config:race",
+		"--compilation_mode", "dbg",
+	}, configurationFlags(flags))
+	assert.Empty(t, configurationFlags([]string{"--test_filter=Foo"}))
+}
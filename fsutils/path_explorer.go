@@ -1,11 +1,14 @@
 package fsutils
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"syscall"
 )
 
 // SearchByFileName Given a path, returns all sub-paths to files that are named exactly like fileName
@@ -57,28 +60,72 @@ func SearchByExtension(rootPath string, ext string) ([]string, error) {
 // startPath may be relative or absolute.
 // On success, it will return an absolute path to a directory containing something named 'contain'
 func SearchClosestParentContaining(startPath string, contains string) (string, error) {
+	dir, _, err := SearchClosestParent(startPath, []string{contains}, SearchOptions{})
+	return dir, err
+}
+
+// SearchOptions changes what SearchClosestParent matches and where it stops
+type SearchOptions struct {
+	// RegularFile only matches regular files (symlinks followed), not directories or other files
+	RegularFile bool
+	// IgnoreErrors treats errors checking a name, i.e. a permission denied, as the name not being there
+	// instead of returning them
+	IgnoreErrors bool
+	// StopAt is the last parent searched, startPath or one of its parents. The root if empty.
+	StopAt string
+}
+
+// SearchClosestParent returns the closest parent (which may be 'startPath' itself) that contains one of 'names',
+// and the name it contains: names are checked in order in each directory and must match exactly.
+// startPath may be relative or absolute, the returned directory is absolute.
+// A path going through a file (ENOTDIR) is never an error, the name is not there.
+func SearchClosestParent(startPath string, names []string, options SearchOptions) (string, string, error) {
 	absPath, err := filepath.Abs(startPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to locate repo root for path: %s", startPath)
+		return "", "", fmt.Errorf("failed to locate repo root for path: %s", startPath)
+	}
+	stopAt := string(filepath.Separator)
+	if options.StopAt != "" {
+		stopAt, err = filepath.Abs(options.StopAt)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to resolve %s: %w", options.StopAt, err)
+		}
+		if rel, err := filepath.Rel(stopAt, absPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", "", fmt.Errorf("%s is not within %s", startPath, options.StopAt)
+		}
 	}
 	var currentLoc = absPath
 	for {
-		_, err = os.Stat(filepath.Join(currentLoc, contains))
-		if err == nil {
-			// We found something and may return immediately
-			return currentLoc, nil
+		for _, name := range names {
+			found, err := contains(filepath.Join(currentLoc, name), options)
+			if err != nil {
+				return "", "", err
+			}
+			if found {
+				return currentLoc, name, nil
+			}
 		}
-		// At this point, if we have an error, it should tell us that the path did not exist
-		if !os.IsNotExist(err) {
-			// Anything else than "NotExist" is an issue we need to report.
-			return "", err
-		}
-		if currentLoc == "/" {
-			return "", fmt.Errorf("failed to locate anything named %s in %s or any of its parents", contains, startPath)
+		parent := filepath.Dir(currentLoc)
+		if currentLoc == stopAt || parent == currentLoc {
+			return "", "", fmt.Errorf("failed to locate anything named %s in %s or any of its parents",
+				strings.Join(names, ", "), startPath)
 		}
 		// Go up one level and start again...
-		currentLoc = filepath.Dir(currentLoc)
+		currentLoc = parent
+	}
+}
+
+// contains returns true if something matching the options exists at path
+func contains(path string, options SearchOptions) (bool, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) || (err != nil && options.IgnoreErrors) {
+		return false, nil
+	}
+	if err != nil {
+		// Anything else than "NotExist" is an issue we need to report.
+		return false, fmt.Errorf("error checking %s: %w", path, err)
 	}
+	return !options.RegularFile || info.Mode().IsRegular(), nil
 }
 
 // filepath.Glob does not support things like '**/file'
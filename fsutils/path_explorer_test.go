@@ -78,3 +78,28 @@ func TestSearchClosestParentContaining(t *testing.T) {
 	assert.NotNil(t, err, "Should not fail if a valid parent exists")
 	assert.Empty(t, empty, "On errors an empty string should be returned")
 }
+
+func TestSearchClosestParent(t *testing.T) {
+	testDir := getTestDir()
+	subDir := filepath.Join(testDir, "sub-dir")
+
+	dir, name, err := SearchClosestParent(subDir, []string{"README", "README.md-ext"}, SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, subDir, dir)
+	assert.Equal(t, "README.md-ext", name, "Expected the first name found in the closest parent")
+
+	dir, _, err = SearchClosestParent(filepath.Join(testDir, "README", "below-a-file"), []string{"README"}, SearchOptions{})
+	assert.NoError(t, err, "Expected paths going through a file not to be an error")
+	assert.Equal(t, testDir, dir)
+
+	dir, _, err = SearchClosestParent(testDir, []string{"sub-dir"}, SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, testDir, dir)
+	_, _, err = SearchClosestParent(testDir, []string{"sub-dir"}, SearchOptions{RegularFile: true, StopAt: testDir})
+	assert.Error(t, err, "Expected directories not to match regular files")
+
+	_, _, err = SearchClosestParent(subDir, []string{"README"}, SearchOptions{StopAt: subDir})
+	assert.Error(t, err, "Expected the search to stop at StopAt")
+	_, _, err = SearchClosestParent(testDir, []string{"README"}, SearchOptions{StopAt: subDir})
+	assert.Error(t, err, "Expected an error if startPath is not within StopAt")
+}
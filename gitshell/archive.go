@@ -0,0 +1,260 @@
+package gitshell
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-ch/go-libs/untar"
+)
+
+// ArchiveFormat is the format of the archive produced by GitArchive
+type ArchiveFormat string
+
+const (
+	// ArchiveTar is an uncompressed tarball
+	ArchiveTar ArchiveFormat = "tar"
+	// ArchiveTarGz is a gzip-compressed tarball, as expected by the untar package
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	// ArchiveZip is a zip file
+	ArchiveZip ArchiveFormat = "zip"
+)
+
+// ArchiveOptions configures the content of an archive
+type ArchiveOptions struct {
+	// Format defaults to ArchiveTar
+	Format ArchiveFormat
+	// Prefix is prepended to every path in the archive, use a trailing slash for a directory
+	Prefix string
+	// Paths limits the archive to the given paths, the whole tree if empty
+	Paths []string
+	// IncludeSubmodules adds the content of the submodules at the commit recorded in rev.
+	// The submodules must be checked out in inPath.
+	IncludeSubmodules bool
+}
+
+// GitArchive writes an archive of the tree at rev to w.
+// see https://git-scm.com/docs/git-archive for more details
+func GitArchive(inPath, rev string, opts ArchiveOptions, w io.Writer) error {
+	format := opts.Format
+	if format == "" {
+		format = ArchiveTar
+	}
+	if format != ArchiveTar && format != ArchiveTarGz && format != ArchiveZip {
+		return fmt.Errorf("unsupported archive format: %s", format)
+	}
+	if !opts.IncludeSubmodules {
+		// git can produce every format on its own
+		cmd := exec.Command("git", archiveArgs(inPath, rev, string(format), opts.Prefix, opts.Paths)...)
+		var stderr bytes.Buffer
+		cmd.Stdout = w
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("git archive failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+
+	aw := newArchiveWriter(format, w)
+	if err := appendArchiveWithSubmodules(aw, inPath, rev, opts); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// GitArchiveToDir exports the tree at rev into dir by piping the archive through untar.
+// The format in opts is ignored. Note that untar only supports regular files and directories,
+// trees containing symlinks cannot be exported.
+func GitArchiveToDir(inPath, rev string, opts ArchiveOptions, dir string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		aw := newArchiveWriter(ArchiveTarGz, pw)
+		err := appendArchiveWithSubmodules(aw, inPath, rev, opts)
+		if closeErr := aw.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	err := untar.Untar(pr, dir)
+	// Unblock the writer if untar stopped early
+	_ = pr.CloseWithError(err)
+	return err
+}
+
+func archiveArgs(inPath, rev, format, prefix string, paths []string) []string {
+	args := []string{"-C", inPath, "archive", "--format=" + format}
+	if prefix != "" {
+		args = append(args, "--prefix="+prefix)
+	}
+	// Neither rev nor the paths must be taken for options, i.e. --output. git archive has no -- separator,
+	// once options end it reads -- as a path.
+	args = append(args, "--end-of-options", rev)
+	return append(args, paths...)
+}
+
+func appendArchiveWithSubmodules(aw *archiveWriter, inPath, rev string, opts ArchiveOptions) error {
+	if err := appendArchive(aw, inPath, rev, opts.Prefix, opts.Paths); err != nil {
+		return err
+	}
+	if !opts.IncludeSubmodules {
+		return nil
+	}
+	return appendSubmodules(aw, inPath, rev, opts.Prefix, opts.Paths)
+}
+
+// appendArchive copies the entries of the git archive of rev to aw
+func appendArchive(aw *archiveWriter, inPath, rev, prefix string, paths []string) error {
+	cmd := exec.Command("git", archiveArgs(inPath, rev, "tar", prefix, paths)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	copyErr := aw.copyFrom(tar.NewReader(stdout))
+	_, _ = io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git archive failed in %s: %w: %s", inPath, err, strings.TrimSpace(stderr.String()))
+	}
+	return copyErr
+}
+
+// appendSubmodules recursively appends the archives of the submodules recorded in rev
+func appendSubmodules(aw *archiveWriter, inPath, rev, prefix string, paths []string) error {
+	cmdOut, err := exec.Command("git", "-C", inPath, "ls-tree", "-r", "-z", "--end-of-options", rev).Output()
+	if err != nil {
+		return fmt.Errorf("error listing submodules of %s: %w", rev, err)
+	}
+	for _, entry := range bytes.Split(cmdOut, []byte{0}) {
+		// <mode> SP <type> SP <object> TAB <path>
+		meta, subPath, found := strings.Cut(string(entry), "\t")
+		fields := strings.Fields(meta)
+		if !found || len(fields) != 3 || fields[1] != "commit" || !archivePathSelected(subPath, paths) {
+			continue
+		}
+		subDir := filepath.Join(inPath, filepath.FromSlash(subPath))
+		if _, err := os.Stat(filepath.Join(subDir, ".git")); err != nil {
+			return fmt.Errorf("submodule %s is not checked out", subPath)
+		}
+		subPrefix := prefix + subPath + "/"
+		if err := appendArchive(aw, subDir, fields[2], subPrefix, nil); err != nil {
+			return err
+		}
+		if err := appendSubmodules(aw, subDir, fields[2], subPrefix, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func archivePathSelected(p string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, selected := range paths {
+		selected = strings.TrimSuffix(selected, "/")
+		if p == selected || strings.HasPrefix(p, selected+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveWriter merges the tar streams of a repository and its submodules into a single archive
+type archiveWriter struct {
+	tw   *tar.Writer
+	gw   *gzip.Writer
+	zw   *zip.Writer
+	dirs map[string]bool
+}
+
+func newArchiveWriter(format ArchiveFormat, w io.Writer) *archiveWriter {
+	aw := &archiveWriter{dirs: map[string]bool{}}
+	switch format {
+	case ArchiveZip:
+		aw.zw = zip.NewWriter(w)
+	case ArchiveTarGz:
+		aw.gw = gzip.NewWriter(w)
+		aw.tw = tar.NewWriter(aw.gw)
+	default:
+		aw.tw = tar.NewWriter(w)
+	}
+	return aw
+}
+
+func (aw *archiveWriter) copyFrom(tr *tar.Reader) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading archive: %w", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeXGlobalHeader:
+			// Only holds the commit id, which differs for each submodule
+			continue
+		case tar.TypeDir:
+			// Submodules show up as directories in their parent as well
+			if aw.dirs[hdr.Name] {
+				continue
+			}
+			aw.dirs[hdr.Name] = true
+		}
+		if err := aw.writeEntry(hdr, tr); err != nil {
+			return fmt.Errorf("error writing %s to archive: %w", hdr.Name, err)
+		}
+	}
+}
+
+func (aw *archiveWriter) writeEntry(hdr *tar.Header, r io.Reader) error {
+	if aw.zw == nil {
+		if err := aw.tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(aw.tw, r)
+		return err
+	}
+
+	fh := &zip.FileHeader{Name: hdr.Name, Modified: hdr.ModTime, Method: zip.Deflate}
+	fh.SetMode(hdr.FileInfo().Mode())
+	if hdr.Typeflag == tar.TypeDir {
+		fh.Name = strings.TrimSuffix(hdr.Name, "/") + "/"
+		fh.Method = zip.Store
+	}
+	fw, err := aw.zw.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeSymlink:
+		_, err = io.WriteString(fw, hdr.Linkname)
+	case tar.TypeReg:
+		_, err = io.Copy(fw, r)
+	}
+	return err
+}
+
+func (aw *archiveWriter) Close() error {
+	if aw.zw != nil {
+		return aw.zw.Close()
+	}
+	if err := aw.tw.Close(); err != nil {
+		return err
+	}
+	if aw.gw != nil {
+		return aw.gw.Close()
+	}
+	return nil
+}
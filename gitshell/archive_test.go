@@ -0,0 +1,143 @@
+package gitshell
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/gitshell/gitshelltest"
+)
+
+func newArchiveRepo(t *testing.T) *gitshelltest.Repo {
+	return gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{
+			gitshelltest.Commit{Files: map[string]string{"README.md": "v1\n", "src/main.go": "package main\n"}},
+			gitshelltest.Tag{Name: "v1"},
+			gitshelltest.Commit{Files: map[string]string{"README.md": "v2\n"}},
+		},
+	})
+}
+
+// newSuperRepo returns a repository with the archive repo as a submodule in lib
+func newSuperRepo(t *testing.T) *gitshelltest.Repo {
+	sub := newArchiveRepo(t)
+	super := gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{gitshelltest.Commit{Files: map[string]string{"top.txt": "top\n"}}},
+	})
+	super.Git("-c", "protocol.file.allow=always", "submodule", "add", "-q", sub.Path, "lib")
+	super.Apply(gitshelltest.Commit{Message: "add submodule"})
+	return super
+}
+
+func tarEntries(t *testing.T, r io.Reader) map[string]string {
+	entries := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		content, _ := io.ReadAll(tr)
+		entries[hdr.Name] = string(content)
+	}
+}
+
+func keys(m map[string]string) []string {
+	var result []string
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestGitArchiveTar(t *testing.T) {
+	repo := newArchiveRepo(t)
+
+	var buf bytes.Buffer
+	err := GitArchive(repo.Path, "v1", ArchiveOptions{Prefix: "project/", Paths: []string{"README.md"}}, &buf)
+	require.NoError(t, err)
+	entries := tarEntries(t, &buf)
+	assert.Equal(t, "v1\n", entries["project/README.md"])
+	assert.NotContains(t, entries, "project/src/main.go")
+}
+
+func TestGitArchiveTarGz(t *testing.T) {
+	repo := newArchiveRepo(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, GitArchive(repo.Path, "HEAD", ArchiveOptions{Format: ArchiveTarGz}, &buf))
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	entries := tarEntries(t, zr)
+	assert.Equal(t, "v2\n", entries["README.md"])
+	assert.Equal(t, "package main\n", entries["src/main.go"])
+}
+
+func TestGitArchiveErrors(t *testing.T) {
+	repo := newArchiveRepo(t)
+
+	assert.Error(t, GitArchive(repo.Path, "HEAD", ArchiveOptions{Format: "rar"}, io.Discard))
+	assert.Error(t, GitArchive(repo.Path, "does-not-exist", ArchiveOptions{}, io.Discard))
+
+	// Revisions starting with - are not options, for git archive and the submodules listing alike
+	output := filepath.Join(t.TempDir(), "archive.tar")
+	for _, opts := range []ArchiveOptions{{}, {IncludeSubmodules: true}} {
+		assert.Error(t, GitArchive(repo.Path, "--output="+output, opts, io.Discard))
+		assert.NoFileExists(t, output)
+	}
+}
+
+func TestGitArchiveSubmodules(t *testing.T) {
+	repo := newSuperRepo(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, GitArchive(repo.Path, "HEAD", ArchiveOptions{IncludeSubmodules: true, Prefix: "out/"}, &buf))
+	entries := tarEntries(t, &buf)
+	assert.Equal(t, []string{
+		"out/",
+		"out/.gitmodules",
+		"out/lib/",
+		"out/lib/README.md",
+		"out/lib/src/",
+		"out/lib/src/main.go",
+		"out/top.txt",
+	}, keys(entries))
+	assert.Equal(t, "v2\n", entries["out/lib/README.md"])
+}
+
+func TestGitArchiveSubmodulesZip(t *testing.T) {
+	repo := newSuperRepo(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, GitArchive(repo.Path, "HEAD", ArchiveOptions{IncludeSubmodules: true, Format: ArchiveZip, Paths: []string{"lib"}}, &buf))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.ElementsMatch(t, []string{"lib/", "lib/README.md", "lib/src/", "lib/src/main.go"}, names)
+}
+
+func TestGitArchiveToDir(t *testing.T) {
+	repo := newSuperRepo(t)
+	dir := t.TempDir()
+
+	require.NoError(t, GitArchiveToDir(repo.Path, "HEAD", ArchiveOptions{IncludeSubmodules: true}, dir))
+	content, err := os.ReadFile(filepath.Join(dir, "lib", "src", "main.go"))
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\n", string(content))
+	_, err = os.Stat(filepath.Join(dir, "top.txt"))
+	assert.NoError(t, err)
+}
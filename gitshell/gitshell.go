@@ -1,7 +1,7 @@
 package gitshell
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"os/exec"
 	"regexp"
@@ -105,27 +105,23 @@ func fromString(modifier string) (GitChange, error) {
 }
 
 // GitFileDiff extracts the map of files and the action that was performed on them: added, modified or delete.
+// If git fails nil is returned, if reading its output fails the files read so far are returned with the error.
+// Use GitFileDiffIterator for very large diffs.
 func GitFileDiff(inPath, previousCommit, currentCommit string) (map[string]GitChange, error) {
 	m := make(map[string]GitChange)
-	var (
-		cmdOut []byte
-		err    error
-	)
-	cmdOut, err = exec.Command("git", "-C", inPath, "diff", "--no-renames", "--name-status", previousCommit, currentCommit).Output()
+	it, err := GitFileDiffIterator(inPath, previousCommit, currentCommit)
 	if err != nil {
 		return nil, err
 	}
-	scanner := bufio.NewScanner(strings.NewReader(string(cmdOut))) // f is the *os.File
-	r := regexp.MustCompile(`\s+`)
-	for scanner.Scan() {
-		s := r.Split(scanner.Text(), 2)
-		if len(s) == 2 {
-			if mod, err := fromString(s[0]); err == nil {
-				m[s[1]] = mod
-			}
-		}
+	for it.Next() {
+		diff := it.FileDiff()
+		m[diff.Path] = diff.Change
 	}
-	if err := scanner.Err(); err != nil {
+	if err := it.Err(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, err
+		}
 		return m, fmt.Errorf("error reading the changed files: %w", err)
 	}
 
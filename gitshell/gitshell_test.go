@@ -0,0 +1,67 @@
+package gitshell
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/open-ch/go-libs/gitshell/gitshelltest"
+)
+
+func TestGitResolveRevision(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{gitshelltest.Commit{Message: "initial"}},
+	})
+
+	sha, err := GitResolveRevision(repo.Path, "main")
+	assert.NoError(t, err)
+	assert.Equal(t, repo.Rev("HEAD"), sha)
+
+	_, err = GitResolveRevision(repo.Path, "does-not-exist")
+	assert.EqualError(t, err, "error cannot resolve passed commit identifier: does-not-exist")
+}
+
+func TestGitCommitMessageFromHash(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{gitshelltest.Commit{Message: "the message"}},
+	})
+
+	message, err := GitCommitMessageFromHash(repo.Path, repo.Rev("HEAD"))
+	assert.NoError(t, err)
+	assert.Equal(t, "the message\n", message)
+}
+
+func TestGitResolveRoot(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{gitshelltest.Commit{Files: map[string]string{"sub/dir/file": "content"}}},
+	})
+
+	root, err := GitResolveRoot(filepath.Join(repo.Path, "sub", "dir"))
+	assert.NoError(t, err)
+	expected, _ := filepath.EvalSymlinks(repo.Path)
+	assert.Equal(t, expected, root)
+}
+
+func TestGitFileDiff(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{
+			gitshelltest.Commit{Files: map[string]string{"modified": "before", "deleted": "gone", "renamed": "moved"}},
+			gitshelltest.Commit{
+				Files:  map[string]string{"modified": "after", "added": "new"},
+				Delete: []string{"deleted"},
+				Rename: map[string]string{"renamed": "sub/renamed"},
+			},
+		},
+	})
+
+	changes, err := GitFileDiff(repo.Path, "HEAD~1", "HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]GitChange{
+		"modified":    Modified,
+		"added":       Added,
+		"deleted":     Deleted,
+		"renamed":     Deleted,
+		"sub/renamed": Added,
+	}, changes)
+}
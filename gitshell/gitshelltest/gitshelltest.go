@@ -0,0 +1,277 @@
+// Package gitshelltest creates throwaway git repositories from a declarative
+// spec, to test code relying on git without depending on the network or on
+// the configuration of the machine running the tests.
+package gitshelltest
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Identity used for all commits and tags created by this package
+const (
+	AuthorName  = "Gitshell Test"
+	AuthorEmail = "gitshelltest@example.com"
+)
+
+// BaseDate is the date of the first commit, every subsequent commit, tag or merge
+// is dated one minute later than the previous one.
+var BaseDate = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Spec declares the content of a repository
+type Spec struct {
+	// DefaultBranch is the initial branch, defaults to main
+	DefaultBranch string
+	// Steps are applied in order
+	Steps []Step
+}
+
+// Step is a single operation on a repository, see Commit, Branch, Checkout, Tag and Merge
+type Step interface {
+	apply(r *Repo) error
+}
+
+// Commit writes, deletes and renames files and commits the result.
+type Commit struct {
+	Message string
+	// Files maps paths to their new content, parent directories are created as needed
+	Files map[string]string
+	// Delete lists the paths to remove
+	Delete []string
+	// Rename maps old paths to new paths
+	Rename map[string]string
+	// Sign signs the commit with the key configured in the repository (user.signingkey)
+	Sign bool
+}
+
+// Branch creates a branch pointing to From (HEAD if empty) without checking it out
+type Branch struct {
+	Name string
+	From string
+}
+
+// Checkout switches to a branch or detaches HEAD at the given revision
+type Checkout struct {
+	Ref string
+}
+
+// Tag tags Ref (HEAD if empty). The tag is annotated if a Message is set.
+type Tag struct {
+	Name    string
+	Ref     string
+	Message string
+	// Sign signs the tag with the key configured in the repository, a Message is required
+	Sign bool
+}
+
+// Merge merges Ref into the current branch. Conflicts fail the step.
+type Merge struct {
+	Ref           string
+	Message       string
+	NoFastForward bool
+}
+
+// Repo is a repository created by NewRepo
+type Repo struct {
+	// Path is the root of the working tree
+	Path  string
+	t     testing.TB
+	ticks int
+}
+
+// NewRepo creates a repository in a temporary directory and applies the spec,
+// failing the test on any error. The directory is removed when the test ends.
+func NewRepo(t testing.TB, spec Spec) *Repo {
+	t.Helper()
+	branch := spec.DefaultBranch
+	if branch == "" {
+		branch = "main"
+	}
+	r := &Repo{Path: t.TempDir(), t: t}
+	r.Git("init", "-q", "-b", branch)
+	r.Apply(spec.Steps...)
+	return r
+}
+
+// Apply applies further steps to the repository, failing the test on any error.
+func (r *Repo) Apply(steps ...Step) {
+	r.t.Helper()
+	for i, step := range steps {
+		if err := step.apply(r); err != nil {
+			r.t.Fatalf("gitshelltest: step %d (%T) failed: %v", i, step, err)
+		}
+	}
+}
+
+// Git runs a git command in the repository with the pinned environment and returns its output,
+// failing the test on any error.
+func (r *Repo) Git(args ...string) string {
+	r.t.Helper()
+	output, err := r.run(args...)
+	if err != nil {
+		r.t.Fatalf("gitshelltest: git %s failed: %v", strings.Join(args, " "), err)
+	}
+	return output
+}
+
+// Rev resolves a revision to its full hash, failing the test on any error.
+func (r *Repo) Rev(revision string) string {
+	r.t.Helper()
+	return strings.TrimSpace(r.Git("rev-parse", "--verify", revision))
+}
+
+// WriteFile writes a file relative to the repository root without adding it.
+func (r *Repo) WriteFile(name, content string) {
+	r.t.Helper()
+	if err := r.writeFile(name, content); err != nil {
+		r.t.Fatalf("gitshelltest: %v", err)
+	}
+}
+
+func (r *Repo) writeFile(name, content string) error {
+	path := filepath.Join(r.Path, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+func (r *Repo) run(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", r.Path}, args...)...)
+	cmd.Env = append(os.Environ(), Env(BaseDate.Add(time.Duration(r.ticks)*time.Minute))...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%w: %s", err, output)
+	}
+	return string(output), nil
+}
+
+// tick moves the clock used for the next commit, tag or merge.
+func (r *Repo) tick() {
+	r.ticks++
+}
+
+// Env returns the environment variables pinning the identity, dates and configuration of git
+// so that the same operations always produce the same hashes.
+func Env(date time.Time) []string {
+	gitDate := date.Format(time.RFC3339)
+	return []string{
+		"GIT_AUTHOR_NAME=" + AuthorName,
+		"GIT_AUTHOR_EMAIL=" + AuthorEmail,
+		"GIT_AUTHOR_DATE=" + gitDate,
+		"GIT_COMMITTER_NAME=" + AuthorName,
+		"GIT_COMMITTER_EMAIL=" + AuthorEmail,
+		"GIT_COMMITTER_DATE=" + gitDate,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=" + os.DevNull,
+		"GIT_TERMINAL_PROMPT=0",
+	}
+}
+
+// PinEnv sets the variables of Env for the duration of the test, so that code under test
+// calling git directly uses the same identity and configuration. All commits are dated BaseDate.
+// Like testing.T.Setenv it cannot be used in parallel tests.
+func PinEnv(t testing.TB) {
+	t.Helper()
+	for _, kv := range Env(BaseDate) {
+		key, value, _ := strings.Cut(kv, "=")
+		t.Setenv(key, value)
+	}
+}
+
+func (c Commit) apply(r *Repo) error {
+	for _, name := range sortedKeys(c.Files) {
+		if err := r.writeFile(name, c.Files[name]); err != nil {
+			return err
+		}
+		if _, err := r.run("add", "--", name); err != nil {
+			return err
+		}
+	}
+	for _, name := range c.Delete {
+		if _, err := r.run("rm", "-q", "--", name); err != nil {
+			return err
+		}
+	}
+	for _, from := range sortedKeys(c.Rename) {
+		to := c.Rename[from]
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(r.Path, filepath.FromSlash(to))), 0755); err != nil {
+			return err
+		}
+		if _, err := r.run("mv", "--", from, to); err != nil {
+			return err
+		}
+	}
+	message := c.Message
+	if message == "" {
+		message = fmt.Sprintf("commit %d", r.ticks)
+	}
+	args := []string{"commit", "-q", "--allow-empty", "-m", message}
+	if c.Sign {
+		args = append(args, "-S")
+	}
+	_, err := r.run(args...)
+	r.tick()
+	return err
+}
+
+func (b Branch) apply(r *Repo) error {
+	args := []string{"branch", b.Name}
+	if b.From != "" {
+		args = append(args, b.From)
+	}
+	_, err := r.run(args...)
+	return err
+}
+
+func (c Checkout) apply(r *Repo) error {
+	_, err := r.run("checkout", "-q", c.Ref)
+	return err
+}
+
+func (tag Tag) apply(r *Repo) error {
+	args := []string{"tag"}
+	if tag.Sign {
+		args = append(args, "-s")
+	}
+	if tag.Message != "" {
+		args = append(args, "-a", "-m", tag.Message)
+	}
+	args = append(args, tag.Name)
+	if tag.Ref != "" {
+		args = append(args, tag.Ref)
+	}
+	_, err := r.run(args...)
+	if tag.Message != "" {
+		r.tick()
+	}
+	return err
+}
+
+func (m Merge) apply(r *Repo) error {
+	args := []string{"merge", "-q", "--no-edit"}
+	if m.NoFastForward {
+		args = append(args, "--no-ff")
+	}
+	if m.Message != "" {
+		args = append(args, "-m", m.Message)
+	}
+	_, err := r.run(append(args, m.Ref)...)
+	r.tick()
+	return err
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
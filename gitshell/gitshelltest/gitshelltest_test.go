@@ -0,0 +1,65 @@
+package gitshelltest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var historySpec = Spec{
+	Steps: []Step{
+		Commit{Message: "initial", Files: map[string]string{"README.md": "hello\n", "docs/guide.md": "guide\n"}},
+		Tag{Name: "v1.0.0", Message: "first release"},
+		Branch{Name: "feature"},
+		Checkout{Ref: "feature"},
+		Commit{Message: "rename guide", Rename: map[string]string{"docs/guide.md": "docs/manual.md"}},
+		Checkout{Ref: "main"},
+		Commit{Message: "drop readme", Delete: []string{"README.md"}},
+		Merge{Ref: "feature", Message: "merge feature", NoFastForward: true},
+		Tag{Name: "v1.1.0"},
+	},
+}
+
+func TestNewRepo(t *testing.T) {
+	repo := NewRepo(t, historySpec)
+
+	assert.Equal(t, "main\n", repo.Git("branch", "--show-current"))
+	assert.Equal(t, repo.Rev("HEAD"), repo.Rev("v1.1.0"))
+	assert.Equal(t, "tag\n", repo.Git("cat-file", "-t", "v1.0.0"))
+	assert.Equal(t, "commit\n", repo.Git("cat-file", "-t", "v1.1.0"))
+
+	parents := strings.Fields(repo.Git("log", "-n", "1", "--pretty=format:%P"))
+	assert.Len(t, parents, 2, "Expected a merge commit")
+
+	files := repo.Git("ls-files")
+	assert.Equal(t, "docs/manual.md\n", files)
+	assert.Equal(t, AuthorName+" <"+AuthorEmail+"> 2020-01-01T00:00:00+00:00", repo.Git("log", "--max-parents=0", "--pretty=format:%an <%ae> %aI"))
+}
+
+func TestNewRepoIsDeterministic(t *testing.T) {
+	first := NewRepo(t, historySpec)
+	second := NewRepo(t, historySpec)
+	assert.Equal(t, first.Rev("HEAD"), second.Rev("HEAD"))
+	assert.Equal(t, first.Rev("v1.0.0"), second.Rev("v1.0.0"))
+}
+
+func TestRepoApply(t *testing.T) {
+	repo := NewRepo(t, Spec{DefaultBranch: "trunk"})
+	repo.Apply(Commit{Files: map[string]string{"a/b/c.txt": "c\n"}})
+	repo.WriteFile("untracked.txt", "u\n")
+
+	assert.Equal(t, "trunk\n", repo.Git("branch", "--show-current"))
+	content, err := os.ReadFile(filepath.Join(repo.Path, "a", "b", "c.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "c\n", string(content))
+	assert.Equal(t, "?? untracked.txt\n", repo.Git("status", "--porcelain"))
+}
+
+func TestPinEnv(t *testing.T) {
+	PinEnv(t)
+	assert.Equal(t, AuthorEmail, os.Getenv("GIT_COMMITTER_EMAIL"))
+	assert.Equal(t, "2020-01-01T00:00:00Z", os.Getenv("GIT_AUTHOR_DATE"))
+}
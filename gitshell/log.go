@@ -0,0 +1,195 @@
+package gitshell
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Commit is a single commit as returned by GitLog
+type Commit struct {
+	Hash           string
+	Parents        []string
+	AuthorName     string
+	AuthorEmail    string
+	AuthorDate     time.Time
+	CommitterName  string
+	CommitterEmail string
+	CommitDate     time.Time
+	Subject        string
+	Body           string
+}
+
+// LogOptions filters the commits returned by GitLog, all set filters must match.
+// see https://git-scm.com/docs/git-log#_commit_limiting for more details
+type LogOptions struct {
+	// Revisions to walk from, i.e. "main" or "v1.0.0..v2.0.0". Defaults to HEAD.
+	Revisions []string
+	// Pickaxe only returns commits changing the number of occurrences of the string (-S)
+	Pickaxe string
+	// PickaxeRegex only returns commits with added or removed lines matching the regex (-G)
+	PickaxeRegex string
+	// Grep only returns commits with a message matching any of the regexes, or all of them with AllMatch
+	Grep     []string
+	AllMatch bool
+	// IgnoreCase makes Grep and Author case insensitive
+	IgnoreCase bool
+	// Author only returns commits with an author name or email matching the regex
+	Author string
+	// Since and Until limit the commit dates, ignored if zero
+	Since time.Time
+	Until time.Time
+	// Paths only returns commits touching any of the paths
+	Paths []string
+	// Follow continues the history of a single path across renames
+	Follow bool
+	// MaxCount limits the number of commits returned, ignored if 0 or less
+	MaxCount int
+}
+
+const commitSeparator = '\x1e'
+
+// The record separator precedes every commit and fields are separated by NUL bytes
+const commitFormat = "--format=%x1e%H%x00%P%x00%an%x00%ae%x00%ad%x00%cn%x00%ce%x00%cd%x00%s%x00%b"
+
+func (opts LogOptions) args() ([]string, error) {
+	args := []string{"log", commitFormat, "--date=raw", "--no-color"}
+	if opts.Pickaxe != "" {
+		args = append(args, "-S"+opts.Pickaxe)
+	}
+	if opts.PickaxeRegex != "" {
+		args = append(args, "-G"+opts.PickaxeRegex)
+	}
+	for _, grep := range opts.Grep {
+		args = append(args, "--grep="+grep)
+	}
+	if opts.AllMatch {
+		args = append(args, "--all-match")
+	}
+	if opts.IgnoreCase {
+		args = append(args, "--regexp-ignore-case")
+	}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author)
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since="+opts.Since.Format(time.RFC3339))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, "--until="+opts.Until.Format(time.RFC3339))
+	}
+	if opts.MaxCount > 0 {
+		args = append(args, fmt.Sprintf("--max-count=%d", opts.MaxCount))
+	}
+	if opts.Follow {
+		if len(opts.Paths) != 1 {
+			return nil, fmt.Errorf("follow requires exactly one path, got %d", len(opts.Paths))
+		}
+		args = append(args, "--follow")
+	}
+	// Revisions starting with - must not be taken for options
+	args = append(args, "--end-of-options")
+	args = append(args, opts.Revisions...)
+	args = append(args, "--")
+	return append(args, opts.Paths...), nil
+}
+
+// GitLog returns the commits matching the options, newest first.
+// Use GitLogIterator for very long histories.
+// see https://git-scm.com/docs/git-log for more details
+func GitLog(inPath string, opts LogOptions) ([]Commit, error) {
+	it, err := GitLogIterator(inPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	for it.Next() {
+		commits = append(commits, it.Commit())
+	}
+	return commits, it.Err()
+}
+
+// CommitIterator reads the commits of a git log one by one as git produces them.
+// Callers must either iterate until Next returns false or call Close.
+type CommitIterator struct {
+	stream *stream
+	commit Commit
+}
+
+// GitLogIterator starts a git log with the given options and returns an iterator over its commits.
+func GitLogIterator(inPath string, opts LogOptions) (*CommitIterator, error) {
+	args, err := opts.args()
+	if err != nil {
+		return nil, err
+	}
+	s, err := startStream(inPath, splitOn(commitSeparator), args...)
+	if err != nil {
+		return nil, err
+	}
+	return &CommitIterator{stream: s}, nil
+}
+
+// Next advances to the next commit, it returns false at the end of the log or on error.
+func (it *CommitIterator) Next() bool {
+	for {
+		record, ok := it.stream.next()
+		if !ok {
+			return false
+		}
+		if len(record) == 0 {
+			continue
+		}
+		commit, err := parseCommit(record)
+		if err != nil {
+			_ = it.stream.close()
+			it.stream.err = err
+			return false
+		}
+		it.commit = commit
+		return true
+	}
+}
+
+// Commit returns the current commit
+func (it *CommitIterator) Commit() Commit {
+	return it.commit
+}
+
+// Err returns the first error encountered. A non zero exit status of git is reported
+// as an error wrapping *exec.ExitError. It is only meaningful once Next returned false.
+func (it *CommitIterator) Err() error {
+	return it.stream.err
+}
+
+// Close stops the iteration early, killing git if it is still running.
+func (it *CommitIterator) Close() error {
+	return it.stream.close()
+}
+
+func parseCommit(record []byte) (Commit, error) {
+	fields := strings.Split(string(record), "\x00")
+	if len(fields) != 10 {
+		return Commit{}, fmt.Errorf("could not parse commit: %q", record)
+	}
+	authorDate, err := parseGitDate(fields[4])
+	if err != nil {
+		return Commit{}, fmt.Errorf("could not parse author date of %s: %w", fields[0], err)
+	}
+	commitDate, err := parseGitDate(fields[7])
+	if err != nil {
+		return Commit{}, fmt.Errorf("could not parse commit date of %s: %w", fields[0], err)
+	}
+	return Commit{
+		Hash:           fields[0],
+		Parents:        strings.Fields(fields[1]),
+		AuthorName:     fields[2],
+		AuthorEmail:    fields[3],
+		AuthorDate:     authorDate,
+		CommitterName:  fields[5],
+		CommitterEmail: fields[6],
+		CommitDate:     commitDate,
+		Subject:        fields[8],
+		// git terminates every record with a newline
+		Body: strings.TrimRight(fields[9], "\n"),
+	}, nil
+}
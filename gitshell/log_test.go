@@ -0,0 +1,100 @@
+package gitshell
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/gitshell/gitshelltest"
+)
+
+func newHistoryRepo(t *testing.T) *gitshelltest.Repo {
+	return gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{
+			gitshelltest.Commit{Message: "initial", Files: map[string]string{"CHANGELOG.md": "# Changelog\n", "version.txt": "1.0.0\n"}},
+			gitshelltest.Commit{Message: "bump version\n\nRelease notes", Files: map[string]string{"version.txt": "1.1.0\n"}},
+			gitshelltest.Commit{Message: "update changelog", Files: map[string]string{"CHANGELOG.md": "# Changelog\n\n1.1.0\n"}},
+			gitshelltest.Commit{Message: "move changelog", Rename: map[string]string{"CHANGELOG.md": "docs/CHANGELOG.md"}},
+			gitshelltest.Commit{Message: "Fix typo", Files: map[string]string{"docs/CHANGELOG.md": "# Changelog\n\n1.1.0 \n"}},
+		},
+	})
+}
+
+func subjects(commits []Commit) []string {
+	var result []string
+	for _, commit := range commits {
+		result = append(result, commit.Subject)
+	}
+	return result
+}
+
+func TestGitLog(t *testing.T) {
+	repo := newHistoryRepo(t)
+
+	all, err := GitLog(repo.Path, LogOptions{})
+	require.NoError(t, err)
+	require.Len(t, all, 5)
+	bump := all[3]
+	assert.Equal(t, repo.Rev("HEAD~3"), bump.Hash)
+	assert.Equal(t, []string{repo.Rev("HEAD~4")}, bump.Parents)
+	assert.Equal(t, "bump version", bump.Subject)
+	assert.Equal(t, "Release notes", bump.Body)
+	assert.Equal(t, gitshelltest.AuthorName, bump.AuthorName)
+	assert.Equal(t, gitshelltest.AuthorEmail, bump.CommitterEmail)
+	assert.True(t, gitshelltest.BaseDate.Add(time.Minute).Equal(bump.AuthorDate))
+	assert.Empty(t, all[4].Parents)
+}
+
+func TestGitLogFilters(t *testing.T) {
+	repo := newHistoryRepo(t)
+
+	tests := []struct {
+		name     string
+		opts     LogOptions
+		expected []string
+	}{
+		{"pickaxe", LogOptions{Pickaxe: "1.1.0"}, []string{"update changelog", "bump version"}},
+		{"pickaxe regex", LogOptions{PickaxeRegex: `^1\.1\.0`}, []string{"Fix typo", "update changelog", "bump version"}},
+		{"grep", LogOptions{Grep: []string{"^fix"}, IgnoreCase: true}, []string{"Fix typo"}},
+		{"grep all match", LogOptions{Grep: []string{"changelog", "^move"}, AllMatch: true}, []string{"move changelog"}},
+		{"author", LogOptions{Author: "nobody"}, nil},
+		{"dates", LogOptions{Since: gitshelltest.BaseDate.Add(time.Minute), Until: gitshelltest.BaseDate.Add(2 * time.Minute)}, []string{"update changelog", "bump version"}},
+		{"path", LogOptions{Paths: []string{"docs/CHANGELOG.md"}}, []string{"Fix typo", "move changelog"}},
+		{"follow", LogOptions{Paths: []string{"docs/CHANGELOG.md"}, Follow: true}, []string{"Fix typo", "move changelog", "update changelog", "initial"}},
+		{"revisions", LogOptions{Revisions: []string{"HEAD~2..HEAD"}, MaxCount: 1}, []string{"Fix typo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits, err := GitLog(repo.Path, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, subjects(commits))
+		})
+	}
+}
+
+func TestGitLogErrors(t *testing.T) {
+	repo := newHistoryRepo(t)
+
+	_, err := GitLog(repo.Path, LogOptions{Follow: true})
+	assert.Error(t, err, "Expected follow without a path to be rejected")
+
+	_, err = GitLog(repo.Path, LogOptions{Revisions: []string{"does-not-exist"}})
+	assert.Error(t, err, "Expected the exit status of git to be reported")
+
+	_, err = GitLog(repo.Path, LogOptions{Revisions: []string{"--output=" + filepath.Join(t.TempDir(), "log")}})
+	assert.Error(t, err, "Expected revisions starting with - not to be taken for options")
+}
+
+func TestGitLogIteratorClose(t *testing.T) {
+	repo := newHistoryRepo(t)
+
+	it, err := GitLogIterator(repo.Path, LogOptions{})
+	require.NoError(t, err)
+	assert.True(t, it.Next())
+	assert.Equal(t, "Fix typo", it.Commit().Subject)
+	assert.NoError(t, it.Close())
+	assert.False(t, it.Next(), "Expected no more commits after closing")
+}
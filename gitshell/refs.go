@@ -0,0 +1,234 @@
+package gitshell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ref is a single reference as listed by for-each-ref.
+type Ref struct {
+	// Name is the full name of the ref, i.e. refs/heads/main
+	Name string
+	// Target is the hash of the object the ref points to
+	Target string
+	// PeeledTarget is the hash of the object an annotated tag points to, empty for other refs
+	PeeledTarget string
+	// Upstream is the full name of the configured upstream for branches, if any
+	Upstream string
+	// ObjectType is the type of the object the ref points to: commit, tag, tree or blob
+	ObjectType string
+}
+
+const refFormat = "%(refname)%00%(objectname)%00%(*objectname)%00%(upstream)%00%(objecttype)"
+
+// ListRefs lists the references matching the given pattern, all refs if the pattern is empty.
+// see https://git-scm.com/docs/git-for-each-ref for the pattern syntax
+func ListRefs(inPath, pattern string) ([]Ref, error) {
+	args := []string{"-C", inPath, "for-each-ref", "--format=" + refFormat}
+	if pattern != "" {
+		args = append(args, pattern)
+	}
+	cmdOut, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	var refs []Ref
+	scanner := bufio.NewScanner(strings.NewReader(string(cmdOut)))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\x00")
+		if len(fields) != 5 {
+			return refs, fmt.Errorf("could not parse ref: %q", scanner.Text())
+		}
+		refs = append(refs, Ref{
+			Name:         fields[0],
+			Target:       fields[1],
+			PeeledTarget: fields[2],
+			Upstream:     fields[3],
+			ObjectType:   fields[4],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return refs, fmt.Errorf("error reading the refs: %w", err)
+	}
+	return refs, nil
+}
+
+// ReflogEntry is a single update of a ref recorded in its reflog.
+type ReflogEntry struct {
+	// OldHash is the value of the ref before the update, it is the all zero hash if the update created the ref.
+	// git log does not print it, it is read from the reflog file and left empty if there is none,
+	// i.e. for repositories using the reftable backend.
+	OldHash   string
+	NewHash   string
+	Name      string
+	Email     string
+	Timestamp time.Time
+	Message   string
+}
+
+// reflogFormat prints the fields of a reflog entry, %gd holds the date of the entry with --date=raw
+const reflogFormat = "%H%x00%gn%x00%ge%x00%gd%x00%gs%x1e"
+
+// Reflog returns the most recent entries of the reflog of the given ref, newest first.
+// A limit of 0 or less returns all entries. The ref may be HEAD, a full ref name
+// or anything git can expand to one (i.e. a branch name).
+// see https://git-scm.com/docs/git-reflog for more details
+func Reflog(inPath, ref string, limit int) ([]ReflogEntry, error) {
+	args := []string{"-C", inPath, "log", "--walk-reflogs", "--date=raw", "--format=" + reflogFormat}
+	if limit > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(limit))
+	}
+	cmdOut, err := exec.Command("git", append(args, "--end-of-options", ref, "--")...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error reading the reflog of %s: %w", ref, err)
+	}
+	var entries []ReflogEntry
+	for _, record := range strings.Split(string(cmdOut), "\x1e") {
+		record = strings.TrimPrefix(record, "\n")
+		if record == "" {
+			continue
+		}
+		entry, err := parseReflogEntry(record)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no reflog for %s", ref)
+	}
+	updates, err := readReflogFile(inPath, ref)
+	if err != nil {
+		return nil, err
+	}
+	// Both list the same updates, the file oldest first
+	for i := range entries {
+		j := len(updates) - 1 - i
+		if j >= 0 && updates[j].newHash == entries[i].NewHash {
+			entries[i].OldHash = updates[j].oldHash
+		}
+	}
+	return entries, nil
+}
+
+// reflogUpdate is the old and new value of a ref recorded in a line of its reflog file
+type reflogUpdate struct {
+	oldHash string
+	newHash string
+}
+
+// readReflogFile reads the updates recorded in the reflog file of a ref, oldest first.
+// It returns no updates if the ref has no reflog file, i.e. with the reftable backend.
+func readReflogFile(inPath, ref string) ([]reflogUpdate, error) {
+	fullName := ref
+	if ref != "HEAD" && !strings.HasPrefix(ref, "refs/") {
+		cmdOut, err := exec.Command("git", "-C", inPath, "rev-parse", "--symbolic-full-name", ref, "--").Output()
+		if err != nil {
+			return nil, fmt.Errorf("error resolving %s: %w", ref, err)
+		}
+		fullName, _, _ = strings.Cut(string(cmdOut), "\n")
+		if fullName == "" {
+			return nil, nil
+		}
+	}
+	cmdOut, err := exec.Command("git", "-C", inPath, "rev-parse", "--git-path", "logs/"+fullName).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error locating the reflog of %s: %w", ref, err)
+	}
+	logPath := strings.TrimSuffix(string(cmdOut), "\n")
+	if !filepath.IsAbs(logPath) {
+		logPath = filepath.Join(inPath, logPath)
+	}
+	content, err := os.ReadFile(logPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the reflog of %s: %w", ref, err)
+	}
+	var updates []reflogUpdate
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		// <old hash> <new hash> <name> <<email>> <timestamp> <tz offset>\t<message>
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("could not parse reflog line: %q", line)
+		}
+		updates = append(updates, reflogUpdate{oldHash: fields[0], newHash: fields[1]})
+	}
+	return updates, nil
+}
+
+// parseReflogEntry parses a record printed with reflogFormat, the selector being of the form
+// main@{<unix timestamp> <tz offset>}
+func parseReflogEntry(record string) (ReflogEntry, error) {
+	fields := strings.Split(record, "\x00")
+	if len(fields) != 5 {
+		return ReflogEntry{}, fmt.Errorf("could not parse reflog entry: %q", record)
+	}
+	selector := fields[3]
+	dateStart := strings.LastIndex(selector, "@{")
+	if dateStart < 0 || !strings.HasSuffix(selector, "}") {
+		return ReflogEntry{}, fmt.Errorf("could not parse reflog selector: %q", selector)
+	}
+	timestamp, err := parseGitDate(selector[dateStart+2 : len(selector)-1])
+	if err != nil {
+		return ReflogEntry{}, fmt.Errorf("could not parse reflog date: %q: %w", selector, err)
+	}
+	return ReflogEntry{
+		NewHash:   fields[0],
+		Name:      fields[1],
+		Email:     fields[2],
+		Timestamp: timestamp,
+		Message:   fields[4],
+	}, nil
+}
+
+// parseGitDate parses git's raw date format: <unix timestamp> <tz offset>
+func parseGitDate(raw string) (time.Time, error) {
+	seconds, offset, found := strings.Cut(raw, " ")
+	if !found || len(offset) != 5 {
+		return time.Time{}, fmt.Errorf("unexpected date format")
+	}
+	unix, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	hours, err := strconv.Atoi(offset[1:3])
+	if err != nil {
+		return time.Time{}, err
+	}
+	minutes, err := strconv.Atoi(offset[3:5])
+	if err != nil {
+		return time.Time{}, err
+	}
+	zoneOffset := hours*3600 + minutes*60
+	if offset[0] == '-' {
+		zoneOffset = -zoneOffset
+	}
+	return time.Unix(unix, 0).In(time.FixedZone(offset, zoneOffset)), nil
+}
+
+// UpdateRef atomically points ref to newValue, if and only if it currently points to oldValue.
+// An empty oldValue skips the check, while an all zero hash requires the ref to not exist yet.
+// The message is recorded in the reflog.
+// see https://git-scm.com/docs/git-update-ref for more details
+func UpdateRef(inPath, ref, newValue, oldValue, message string) (string, error) {
+	args := []string{"-C", inPath, "update-ref"}
+	if message != "" {
+		args = append(args, "-m", message)
+	}
+	args = append(args, ref, newValue)
+	if oldValue != "" {
+		args = append(args, oldValue)
+	}
+	output, err := exec.Command("git", args...).CombinedOutput()
+	return string(output), err
+}
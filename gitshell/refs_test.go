@@ -0,0 +1,99 @@
+package gitshell
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/gitshell/gitshelltest"
+)
+
+func TestListRefs(t *testing.T) {
+	dir := newTestRepo(t)
+	output, err := exec.Command("git", "-C", dir, "tag", "-a", "-m", "release", "v1.0.0").CombinedOutput()
+	require.NoError(t, err, string(output))
+	head, _ := GitResolveRevision(dir, "HEAD")
+
+	refs, err := ListRefs(dir, "")
+	assert.NoError(t, err)
+	require.Len(t, refs, 2)
+	assert.Equal(t, Ref{Name: "refs/heads/main", Target: head, ObjectType: "commit"}, refs[0])
+	assert.Equal(t, "refs/tags/v1.0.0", refs[1].Name)
+	assert.Equal(t, "tag", refs[1].ObjectType)
+	assert.Equal(t, head, refs[1].PeeledTarget, "Expected the annotated tag to be peeled to the commit")
+
+	tags, err := ListRefs(dir, "refs/heads")
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+}
+
+func TestReflogAndUpdateRef(t *testing.T) {
+	dir := newTestRepo(t)
+	head, _ := GitResolveRevision(dir, "HEAD")
+	parent, _ := GitResolveRevision(dir, "HEAD~1")
+
+	_, err := UpdateRef(dir, "refs/heads/main", parent, parent, "wrong old value")
+	assert.Error(t, err, "Expected the update to fail if the old value does not match")
+
+	_, err = UpdateRef(dir, "refs/heads/main", parent, head, "move back")
+	assert.NoError(t, err)
+
+	entries, err := Reflog(dir, "main", 0)
+	assert.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, head, entries[0].OldHash)
+	assert.Equal(t, parent, entries[0].NewHash)
+	assert.Equal(t, "move back", entries[0].Message)
+	assert.Equal(t, gitshelltest.AuthorName, entries[0].Name)
+	assert.Equal(t, gitshelltest.AuthorEmail, entries[0].Email)
+	assert.True(t, gitshelltest.BaseDate.Equal(entries[0].Timestamp))
+	assert.Equal(t, "0000000000000000000000000000000000000000", entries[2].OldHash)
+
+	limited, err := Reflog(dir, "refs/heads/main", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, entries[:1], limited)
+
+	headEntries, err := Reflog(dir, "HEAD", 0)
+	assert.NoError(t, err)
+	require.NotEmpty(t, headEntries)
+	assert.Equal(t, parent, headEntries[0].NewHash)
+	assert.Equal(t, head, headEntries[0].OldHash)
+
+	_, err = Reflog(dir, "does-not-exist", 0)
+	assert.Error(t, err)
+}
+
+func TestReflogSHA256(t *testing.T) {
+	gitshelltest.PinEnv(t)
+	dir := t.TempDir()
+	output, err := exec.Command("git", "init", "--object-format=sha256", "--initial-branch=main", dir).CombinedOutput()
+	require.NoError(t, err, string(output))
+	output, err = exec.Command("git", "-C", dir, "commit", "--allow-empty", "-m", "initial").CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	entries, err := Reflog(dir, "main", 0)
+	assert.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Len(t, entries[0].NewHash, 64)
+	assert.Equal(t, strings.Repeat("0", 64), entries[0].OldHash, "Expected the zero hash of the object format")
+}
+
+func TestParseReflogEntry(t *testing.T) {
+	entry, err := parseReflogEntry("2222222222222222222222222222222222222222\x00Jane Doe\x00jane@example.com\x00main@{1600000000 -0130}\x00commit: fix\tbug")
+	assert.NoError(t, err)
+	assert.Equal(t, "2222222222222222222222222222222222222222", entry.NewHash)
+	assert.Equal(t, "Jane Doe", entry.Name)
+	assert.Equal(t, "jane@example.com", entry.Email)
+	assert.Equal(t, "commit: fix\tbug", entry.Message)
+	assert.Equal(t, int64(1600000000), entry.Timestamp.Unix())
+	_, offset := entry.Timestamp.Zone()
+	assert.Equal(t, -5400, offset)
+
+	_, err = parseReflogEntry("garbage")
+	assert.Error(t, err)
+	_, err = parseReflogEntry("2222222222222222222222222222222222222222\x00Jane\x00j@example.com\x00main\x00msg")
+	assert.Error(t, err)
+}
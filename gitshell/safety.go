@@ -0,0 +1,192 @@
+package gitshell
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// GitStatusEntry is a single entry of the working tree status.
+// Index and WorkTree hold the X and Y status codes of the porcelain format.
+// see https://git-scm.com/docs/git-status#_short_format for more details
+type GitStatusEntry struct {
+	Index    byte
+	WorkTree byte
+	Path     string
+	// OrigPath is only set for renamed or copied entries
+	OrigPath string
+}
+
+// Untracked returns true if the entry is a file not known to git
+func (e GitStatusEntry) Untracked() bool {
+	return e.Index == '?' && e.WorkTree == '?'
+}
+
+// GitStatus lists the paths that differ between HEAD, the index and the working tree.
+// Ignored files are not reported. An empty slice means the working tree is clean.
+// see https://git-scm.com/docs/git-status for more details
+func GitStatus(inPath string) ([]GitStatusEntry, error) {
+	cmdOut, err := exec.Command("git", "-C", inPath, "status", "--porcelain=v1", "-z", "--untracked-files=all").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(cmdOut)
+}
+
+func parseStatus(out []byte) ([]GitStatusEntry, error) {
+	var entries []GitStatusEntry
+	fields := bytes.Split(bytes.TrimSuffix(out, []byte{0}), []byte{0})
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if len(field) == 0 {
+			continue
+		}
+		if len(field) < 4 {
+			return entries, fmt.Errorf("could not parse status entry: %q", field)
+		}
+		entry := GitStatusEntry{
+			Index:    field[0],
+			WorkTree: field[1],
+			Path:     string(field[3:]),
+		}
+		// Renames and copies are followed by the original path as a separate field
+		if entry.Index == 'R' || entry.Index == 'C' {
+			i++
+			if i >= len(fields) {
+				return entries, fmt.Errorf("missing original path for status entry: %q", field)
+			}
+			entry.OrigPath = string(fields[i])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// DirtyWorkingTreeError is returned when a destructive operation is refused
+// because the working tree contains uncommitted changes.
+type DirtyWorkingTreeError struct {
+	Operation string
+	Paths     []string
+}
+
+func (e *DirtyWorkingTreeError) Error() string {
+	return fmt.Sprintf("refusing to %s, working tree has uncommitted changes: %s", e.Operation, strings.Join(e.Paths, ", "))
+}
+
+// BackupMode defines what is saved before a destructive operation proceeds.
+type BackupMode int
+
+const (
+	// NoBackup does not save anything
+	NoBackup BackupMode = iota
+	// BackupRef saves HEAD and tracked changes to a commit referenced under SafetyOptions.BackupRefPrefix.
+	// Untracked files are not part of the backup.
+	BackupRef
+	// BackupStash stashes all changes, including untracked files unless SafetyOptions.IgnoreUntracked is set.
+	BackupStash
+)
+
+// DefaultBackupRefPrefix is the namespace used for backup refs when none is configured
+const DefaultBackupRefPrefix = "refs/gitshell-backup"
+
+// SafetyOptions configures the pre-flight checks of destructive operations.
+type SafetyOptions struct {
+	// Force proceeds even if the working tree is dirty
+	Force bool
+	// IgnoreUntracked does not consider untracked files as uncommitted changes
+	IgnoreUntracked bool
+	// Backup selects what is saved before proceeding
+	Backup BackupMode
+	// BackupRefPrefix is the namespace for BackupRef, defaults to DefaultBackupRefPrefix
+	BackupRefPrefix string
+}
+
+// Preflight checks the working tree status before a destructive operation.
+// If there are uncommitted changes and opts.Force is not set a *DirtyWorkingTreeError is returned.
+// Otherwise the backup requested in opts is created and its name returned:
+// the ref for BackupRef, the stash commit hash for BackupStash, or an empty string
+// if there was nothing to back up.
+func Preflight(inPath, operation string, opts SafetyOptions) (string, error) {
+	entries, err := GitStatus(inPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading the working tree status: %w", err)
+	}
+	var dirty []string
+	for _, entry := range entries {
+		if opts.IgnoreUntracked && entry.Untracked() {
+			continue
+		}
+		dirty = append(dirty, entry.Path)
+	}
+	if len(dirty) > 0 && !opts.Force {
+		return "", &DirtyWorkingTreeError{Operation: operation, Paths: dirty}
+	}
+
+	switch opts.Backup {
+	case BackupRef:
+		return backupToRef(inPath, operation, opts.BackupRefPrefix)
+	case BackupStash:
+		if len(dirty) == 0 {
+			return "", nil
+		}
+		return backupToStash(inPath, operation, !opts.IgnoreUntracked)
+	default:
+		return "", nil
+	}
+}
+
+func backupToRef(inPath, operation, prefix string) (string, error) {
+	if prefix == "" {
+		prefix = DefaultBackupRefPrefix
+	}
+	// stash create builds a commit of the tracked changes without touching the working tree,
+	// it prints nothing if there are no changes in which case HEAD is enough.
+	cmdOut, err := exec.Command("git", "-C", inPath, "stash", "create", "gitshell backup before "+operation).Output()
+	if err != nil {
+		return "", fmt.Errorf("error creating backup commit: %w", err)
+	}
+	target := strings.TrimSpace(string(cmdOut))
+	if target == "" {
+		target = "HEAD"
+	}
+	ref := fmt.Sprintf("%s/%s-%d", strings.TrimSuffix(prefix, "/"), operation, time.Now().UnixNano())
+	if output, err := exec.Command("git", "-C", inPath, "update-ref", ref, target).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("error creating backup ref %s: %s", ref, output)
+	}
+	return ref, nil
+}
+
+func backupToStash(inPath, operation string, includeUntracked bool) (string, error) {
+	args := []string{"-C", inPath, "stash", "push", "-m", "gitshell backup before " + operation}
+	if includeUntracked {
+		args = append(args, "--include-untracked")
+	}
+	output, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("error stashing changes: %s", output)
+	}
+	return GitResolveRevision(inPath, "refs/stash")
+}
+
+// GitSafeReset hard resets to the given commit after the pre-flight checks of Preflight passed.
+// It returns the output of git and the backup created by Preflight, empty if there is none.
+func GitSafeReset(inPath, commit string, opts SafetyOptions) (output, backup string, err error) {
+	if backup, err = Preflight(inPath, "reset", opts); err != nil {
+		return "", "", err
+	}
+	output, err = GitReset(inPath, commit)
+	return output, backup, err
+}
+
+// GitSafeCheckout checks out the given commit or branch after the pre-flight checks of Preflight passed.
+// It returns the output of git and the backup created by Preflight, empty if there is none.
+// Note that with BackupStash the changes are not carried over to the checked out revision.
+func GitSafeCheckout(inPath, commitOrBranch string, opts SafetyOptions) (output, backup string, err error) {
+	if backup, err = Preflight(inPath, "checkout", opts); err != nil {
+		return "", "", err
+	}
+	output, err = GitCheckout(inPath, commitOrBranch)
+	return output, backup, err
+}
@@ -0,0 +1,136 @@
+package gitshell
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/gitshell/gitshelltest"
+)
+
+// newTestRepo creates a repository with two commits, the second adding README.md
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	gitshelltest.PinEnv(t)
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{
+			gitshelltest.Commit{Message: "initial"},
+			gitshelltest.Commit{Message: "add readme", Files: map[string]string{"README.md": "hello\n"}},
+		},
+	})
+	return repo.Path
+}
+
+func writeTestFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+}
+
+func TestGitStatus(t *testing.T) {
+	dir := newTestRepo(t)
+
+	clean, err := GitStatus(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, clean, "Expected a clean working tree")
+
+	writeTestFile(t, dir, "README.md", "changed\n")
+	writeTestFile(t, dir, "new.txt", "new\n")
+	dirty, err := GitStatus(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []GitStatusEntry{
+		{Index: ' ', WorkTree: 'M', Path: "README.md"},
+		{Index: '?', WorkTree: '?', Path: "new.txt"},
+	}, dirty)
+}
+
+func TestParseStatusRename(t *testing.T) {
+	entries, err := parseStatus([]byte("R  new.md\x00old.md\x00?? other\x00"))
+	assert.NoError(t, err)
+	assert.Equal(t, []GitStatusEntry{
+		{Index: 'R', WorkTree: ' ', Path: "new.md", OrigPath: "old.md"},
+		{Index: '?', WorkTree: '?', Path: "other"},
+	}, entries)
+
+	_, err = parseStatus([]byte("R  new.md\x00"))
+	assert.Error(t, err, "Expected an error when the original path is missing")
+}
+
+func TestGitSafeReset(t *testing.T) {
+	dir := newTestRepo(t)
+	writeTestFile(t, dir, "README.md", "changed\n")
+
+	_, _, err := GitSafeReset(dir, "HEAD", SafetyOptions{})
+	var dirtyErr *DirtyWorkingTreeError
+	assert.True(t, errors.As(err, &dirtyErr), "Expected a DirtyWorkingTreeError, got: %v", err)
+	assert.Equal(t, []string{"README.md"}, dirtyErr.Paths)
+
+	_, backup, err := GitSafeReset(dir, "HEAD", SafetyOptions{Force: true, Backup: BackupRef})
+	assert.NoError(t, err)
+	content, _ := os.ReadFile(filepath.Join(dir, "README.md"))
+	assert.Equal(t, "hello\n", string(content))
+	output, err := exec.Command("git", "-C", dir, "show", backup+":README.md").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "changed\n", string(output), "Expected the backup ref to be returned")
+}
+
+func TestGitSafeCheckoutIgnoreUntracked(t *testing.T) {
+	dir := newTestRepo(t)
+	writeTestFile(t, dir, "untracked.txt", "new\n")
+
+	_, _, err := GitSafeCheckout(dir, "HEAD~1", SafetyOptions{})
+	assert.Error(t, err, "Expected untracked files to block the checkout")
+
+	_, backup, err := GitSafeCheckout(dir, "HEAD~1", SafetyOptions{IgnoreUntracked: true, Backup: BackupStash})
+	assert.NoError(t, err)
+	assert.Empty(t, backup, "Expected ignored untracked files not to be stashed")
+	_, err = os.Stat(filepath.Join(dir, "untracked.txt"))
+	assert.NoError(t, err)
+}
+
+func TestPreflightBackupRef(t *testing.T) {
+	dir := newTestRepo(t)
+	writeTestFile(t, dir, "README.md", "changed\n")
+
+	ref, err := Preflight(dir, "reset", SafetyOptions{Force: true, Backup: BackupRef})
+	assert.NoError(t, err)
+	assert.Regexp(t, "^refs/gitshell-backup/reset-[0-9]+$", ref)
+
+	_, err = GitReset(dir, "HEAD")
+	require.NoError(t, err)
+	output, err := exec.Command("git", "-C", dir, "show", ref+":README.md").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "changed\n", string(output), "Expected the backup to contain the discarded change")
+}
+
+func TestPreflightBackupStash(t *testing.T) {
+	dir := newTestRepo(t)
+
+	nothing, err := Preflight(dir, "reset", SafetyOptions{Backup: BackupStash})
+	assert.NoError(t, err)
+	assert.Empty(t, nothing, "Expected no stash on a clean working tree")
+
+	writeTestFile(t, dir, "untracked.txt", "new\n")
+	stash, err := Preflight(dir, "reset", SafetyOptions{Force: true, Backup: BackupStash})
+	assert.NoError(t, err)
+	assert.Len(t, stash, 40)
+	entries, _ := GitStatus(dir)
+	assert.Empty(t, entries, "Expected the stash to include untracked files")
+}
+
+func TestPreflightBackupStashIgnoreUntracked(t *testing.T) {
+	dir := newTestRepo(t)
+	writeTestFile(t, dir, "README.md", "changed\n")
+	writeTestFile(t, dir, "untracked.txt", "new\n")
+
+	stash, err := Preflight(dir, "reset", SafetyOptions{Force: true, IgnoreUntracked: true, Backup: BackupStash})
+	assert.NoError(t, err)
+	assert.Len(t, stash, 40)
+	entries, _ := GitStatus(dir)
+	assert.Equal(t, []GitStatusEntry{{Index: '?', WorkTree: '?', Path: "untracked.txt"}}, entries,
+		"Expected untracked files to be left alone")
+}
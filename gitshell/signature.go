@@ -0,0 +1,250 @@
+package gitshell
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// SignatureStatus mirrors the signature status codes git uses for the %G? log placeholder
+type SignatureStatus string
+
+const (
+	// SignatureGood is a good and valid signature
+	SignatureGood SignatureStatus = "G"
+	// SignatureBad is a bad signature
+	SignatureBad SignatureStatus = "B"
+	// SignatureUnknownValidity is a good signature from a key that is not trusted
+	SignatureUnknownValidity SignatureStatus = "U"
+	// SignatureExpired is a good signature that has expired
+	SignatureExpired SignatureStatus = "X"
+	// SignatureExpiredKey is a good signature made by an expired key
+	SignatureExpiredKey SignatureStatus = "Y"
+	// SignatureRevokedKey is a good signature made by a revoked key
+	SignatureRevokedKey SignatureStatus = "R"
+	// SignatureCannotCheck is a signature that cannot be checked, i.e. because of a missing key
+	SignatureCannotCheck SignatureStatus = "E"
+	// SignatureNone means the object is not signed
+	SignatureNone SignatureStatus = "N"
+)
+
+// SignatureFormat is the kind of signature of an object
+type SignatureFormat string
+
+const (
+	// FormatUnsigned is used for objects without signature
+	FormatUnsigned SignatureFormat = ""
+	// FormatGPG is an OpenPGP signature
+	FormatGPG SignatureFormat = "openpgp"
+	// FormatSSH is an SSH signature
+	FormatSSH SignatureFormat = "ssh"
+	// FormatX509 is an X.509 (S/MIME) signature
+	FormatX509 SignatureFormat = "x509"
+)
+
+// SignatureVerification is the result of verifying the signature of a commit or tag
+type SignatureVerification struct {
+	Status SignatureStatus
+	Format SignatureFormat
+	// KeyID is the long key id for GPG and X.509, or the key fingerprint for SSH
+	KeyID string
+	// Fingerprint is the full fingerprint of the signing key, when reported
+	Fingerprint string
+	// Signer is the user id for GPG and X.509, or the principal from the allowed signers file for SSH
+	Signer string
+	// TrustLevel is the GPG trust level of the key (i.e. ULTIMATE), when reported
+	TrustLevel string
+	// Raw is the raw output of the verification
+	Raw string
+}
+
+// Trusted returns true if the signature is good and made by a trusted key
+func (v *SignatureVerification) Trusted() bool {
+	return v.Status == SignatureGood
+}
+
+// signatureMarker is the first and last line of a signature of the given format
+type signatureMarker struct {
+	begin  string
+	end    string
+	format SignatureFormat
+}
+
+// signatureMarkers are checked in order, like git does
+var signatureMarkers = []signatureMarker{
+	{begin: "-----BEGIN PGP SIGNATURE-----", end: "-----END PGP SIGNATURE-----", format: FormatGPG},
+	{begin: "-----BEGIN PGP MESSAGE-----", end: "-----END PGP MESSAGE-----", format: FormatGPG},
+	{begin: "-----BEGIN SIGNED MESSAGE-----", end: "-----END SIGNED MESSAGE-----", format: FormatX509},
+	{begin: "-----BEGIN SSH SIGNATURE-----", end: "-----END SSH SIGNATURE-----", format: FormatSSH},
+}
+
+var (
+	sshGoodSignature      = regexp.MustCompile(`Good "git" signature for (.+) with (\S+) key (\S+)`)
+	sshUntrustedSignature = regexp.MustCompile(`Good "git" signature with (\S+) key (\S+)`)
+)
+
+// VerifyCommit verifies the signature of a commit.
+// Unsigned commits and bad signatures are not errors, inspect the Status of the result.
+// An error is only returned if the commit cannot be read or verified at all.
+// see https://git-scm.com/docs/git-verify-commit for more details
+func VerifyCommit(inPath, rev string) (*SignatureVerification, error) {
+	return verifySignature(inPath, "commit", rev)
+}
+
+// VerifyTag verifies the signature of an annotated tag.
+// Unsigned tags and bad signatures are not errors, inspect the Status of the result.
+// An error is only returned if the tag cannot be read or verified at all.
+// see https://git-scm.com/docs/git-verify-tag for more details
+func VerifyTag(inPath, tag string) (*SignatureVerification, error) {
+	return verifySignature(inPath, "tag", tag)
+}
+
+func verifySignature(inPath, objectType, name string) (*SignatureVerification, error) {
+	// name must not be taken for an option
+	object, err := exec.Command("git", "-C", inPath, "cat-file", objectType, "--end-of-options", name).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error cannot read %s %s: %w", objectType, name, err)
+	}
+	var format SignatureFormat
+	if objectType == "commit" {
+		format = signatureFormat(commitSignature(string(object)))
+	} else {
+		format = tagSignatureFormat(string(object))
+	}
+	if format == FormatUnsigned {
+		return &SignatureVerification{Status: SignatureNone}, nil
+	}
+
+	// verify-* exits non zero for anything but good signatures, the status is in the output
+	output, _ := exec.Command("git", "-C", inPath, "verify-"+objectType, "--raw", "--end-of-options", name).CombinedOutput()
+	verification := &SignatureVerification{Format: format, Raw: string(output)}
+	if format == FormatSSH {
+		parseSSHVerification(verification)
+	} else {
+		parseGPGVerification(verification)
+	}
+	return verification, nil
+}
+
+// commitSignature returns the value of the gpgsig or gpgsig-sha256 header of a commit, empty if there is none.
+// Other headers, i.e. the signed tags of a mergetag header, and the message are ignored.
+func commitSignature(commit string) string {
+	headers, _, _ := strings.Cut(commit, "\n\n")
+	var signature []string
+	inSignature := false
+	for _, line := range strings.Split(headers, "\n") {
+		// Multi-line header values continue on lines starting with a space
+		if strings.HasPrefix(line, " ") {
+			if inSignature {
+				signature = append(signature, line[1:])
+			}
+			continue
+		}
+		if len(signature) > 0 {
+			break
+		}
+		name, value, _ := strings.Cut(line, " ")
+		inSignature = name == "gpgsig" || name == "gpgsig-sha256"
+		if inSignature {
+			signature = append(signature, value)
+		}
+	}
+	return strings.Join(signature, "\n")
+}
+
+// tagSignatureFormat returns the format of the signature block ending a tag, the message included in
+// the tag being ignored. Signatures quoted in the message are not taken into account.
+func tagSignatureFormat(tag string) SignatureFormat {
+	_, body, _ := strings.Cut(tag, "\n\n")
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	last := lines[len(lines)-1]
+	for _, marker := range signatureMarkers {
+		if last != marker.end {
+			continue
+		}
+		// The block starts at the last begin line before the end line
+		for i := len(lines) - 2; i >= 0; i-- {
+			if lines[i] == marker.begin {
+				return marker.format
+			}
+		}
+	}
+	return FormatUnsigned
+}
+
+// signatureFormat returns the format of a signature, detected by its first line
+func signatureFormat(signature string) SignatureFormat {
+	for _, marker := range signatureMarkers {
+		if strings.HasPrefix(signature, marker.begin) {
+			return marker.format
+		}
+	}
+	return FormatUnsigned
+}
+
+// parseSSHVerification interprets the output of ssh-keygen -Y verify as relayed by git
+func parseSSHVerification(v *SignatureVerification) {
+	if match := sshGoodSignature.FindStringSubmatch(v.Raw); match != nil {
+		v.Status = SignatureGood
+		v.Signer = match[1]
+		v.KeyID = match[3]
+		v.Fingerprint = match[3]
+		return
+	}
+	// The signature is valid but the key is not in the allowed signers file
+	if match := sshUntrustedSignature.FindStringSubmatch(v.Raw); match != nil {
+		v.Status = SignatureUnknownValidity
+		v.KeyID = match[2]
+		v.Fingerprint = match[2]
+		return
+	}
+	v.Status = SignatureBad
+}
+
+var gpgStatuses = map[string]SignatureStatus{
+	"GOODSIG":   SignatureGood,
+	"BADSIG":    SignatureBad,
+	"EXPSIG":    SignatureExpired,
+	"EXPKEYSIG": SignatureExpiredKey,
+	"REVKEYSIG": SignatureRevokedKey,
+	"ERRSIG":    SignatureCannotCheck,
+}
+
+// parseGPGVerification interprets the status lines of gpg and gpgsm
+// see https://github.com/gpg/gnupg/blob/master/doc/DETAILS#format-of-the-status-fd-output
+func parseGPGVerification(v *SignatureVerification) {
+	v.Status = SignatureCannotCheck
+	scanner := bufio.NewScanner(strings.NewReader(v.Raw))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "[GNUPG:] ") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "[GNUPG:] "))
+		if len(fields) == 0 {
+			continue
+		}
+		keyword := fields[0]
+		if status, ok := gpgStatuses[keyword]; ok {
+			v.Status = status
+			if len(fields) > 1 {
+				v.KeyID = fields[1]
+			}
+			if len(fields) > 2 && keyword != "ERRSIG" {
+				v.Signer = strings.Join(fields[2:], " ")
+			}
+			continue
+		}
+		switch {
+		case keyword == "VALIDSIG" && len(fields) > 1:
+			v.Fingerprint = fields[1]
+		case strings.HasPrefix(keyword, "TRUST_"):
+			v.TrustLevel = strings.TrimPrefix(keyword, "TRUST_")
+		}
+	}
+	if v.Status == SignatureGood && (v.TrustLevel == "UNDEFINED" || v.TrustLevel == "NEVER") {
+		v.Status = SignatureUnknownValidity
+	}
+}
@@ -0,0 +1,207 @@
+package gitshell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/gitshell/gitshelltest"
+)
+
+// generateSSHKey creates an ed25519 key pair and returns the path to the public key
+func generateSSHKey(t *testing.T, dir, name string) string {
+	t.Helper()
+	key := filepath.Join(dir, name)
+	output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", name, "-f", key).CombinedOutput()
+	require.NoError(t, err, string(output))
+	return key + ".pub"
+}
+
+func newSignedRepo(t *testing.T) *gitshelltest.Repo {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is required to test SSH signatures")
+	}
+	keys := t.TempDir()
+	trusted := generateSSHKey(t, keys, "trusted")
+	untrusted := generateSSHKey(t, keys, "untrusted")
+	publicKey, err := os.ReadFile(trusted)
+	require.NoError(t, err)
+	allowedSigners := filepath.Join(keys, "allowed_signers")
+	require.NoError(t, os.WriteFile(allowedSigners, append([]byte(gitshelltest.AuthorEmail+" "), publicKey...), 0600))
+
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{})
+	repo.Git("config", "gpg.format", "ssh")
+	repo.Git("config", "gpg.ssh.allowedSignersFile", allowedSigners)
+	repo.Git("config", "user.signingkey", trusted)
+	repo.Apply(
+		gitshelltest.Commit{Message: "unsigned"},
+		gitshelltest.Tag{Name: "unsigned-tag", Message: "unsigned"},
+		gitshelltest.Commit{Message: "signed", Sign: true},
+		gitshelltest.Tag{Name: "signed-tag", Message: "signed", Sign: true},
+	)
+	repo.Git("config", "user.signingkey", untrusted)
+	repo.Apply(gitshelltest.Commit{Message: "signed by someone else", Sign: true})
+	return repo
+}
+
+func TestVerifyCommit(t *testing.T) {
+	repo := newSignedRepo(t)
+
+	good, err := VerifyCommit(repo.Path, "HEAD~1")
+	require.NoError(t, err)
+	assert.Equal(t, SignatureGood, good.Status, good.Raw)
+	assert.Equal(t, FormatSSH, good.Format)
+	assert.Equal(t, gitshelltest.AuthorEmail, good.Signer)
+	assert.Regexp(t, "^SHA256:", good.KeyID)
+	assert.True(t, good.Trusted())
+
+	untrusted, err := VerifyCommit(repo.Path, "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, SignatureUnknownValidity, untrusted.Status, untrusted.Raw)
+	assert.NotEqual(t, good.KeyID, untrusted.KeyID)
+	assert.False(t, untrusted.Trusted())
+
+	unsigned, err := VerifyCommit(repo.Path, "HEAD~2")
+	require.NoError(t, err)
+	assert.Equal(t, &SignatureVerification{Status: SignatureNone}, unsigned)
+
+	_, err = VerifyCommit(repo.Path, "does-not-exist")
+	assert.Error(t, err)
+
+	_, err = VerifyCommit(repo.Path, "-p")
+	assert.Error(t, err, "Expected names starting with - not to be taken for options")
+}
+
+func TestVerifyTag(t *testing.T) {
+	repo := newSignedRepo(t)
+
+	good, err := VerifyTag(repo.Path, "signed-tag")
+	require.NoError(t, err)
+	assert.Equal(t, SignatureGood, good.Status, good.Raw)
+	assert.Equal(t, gitshelltest.AuthorEmail, good.Signer)
+
+	unsigned, err := VerifyTag(repo.Path, "unsigned-tag")
+	require.NoError(t, err)
+	assert.Equal(t, SignatureNone, unsigned.Status)
+
+	_, err = VerifyTag(repo.Path, "-v")
+	assert.Error(t, err, "Expected names starting with - not to be taken for options")
+}
+
+func TestParseGPGVerification(t *testing.T) {
+	v := &SignatureVerification{Raw: `gpg: Signature made Mon 01 Jan 2020
+[GNUPG:] NEWSIG
+[GNUPG:] KEY_CONSIDERED 0123456789ABCDEF0123456789ABCDEF01234567 0
+[GNUPG:] GOODSIG 89ABCDEF01234567 Jane Doe <jane@example.com>
+[GNUPG:] VALIDSIG 0123456789ABCDEF0123456789ABCDEF01234567 2020-01-01 1577836800 0 4 0 22 10 00 0123456789ABCDEF0123456789ABCDEF01234567
+[GNUPG:] TRUST_ULTIMATE 0 pgp
+`}
+	parseGPGVerification(v)
+	assert.Equal(t, SignatureGood, v.Status)
+	assert.Equal(t, "89ABCDEF01234567", v.KeyID)
+	assert.Equal(t, "Jane Doe <jane@example.com>", v.Signer)
+	assert.Equal(t, "0123456789ABCDEF0123456789ABCDEF01234567", v.Fingerprint)
+	assert.Equal(t, "ULTIMATE", v.TrustLevel)
+
+	untrusted := &SignatureVerification{Raw: "[GNUPG:] GOODSIG 89ABCDEF01234567 Jane\n[GNUPG:] TRUST_UNDEFINED 0 pgp\n"}
+	parseGPGVerification(untrusted)
+	assert.Equal(t, SignatureUnknownValidity, untrusted.Status)
+
+	missing := &SignatureVerification{Raw: "[GNUPG:] ERRSIG 89ABCDEF01234567 1 10 00 1577836800 9 -\n[GNUPG:] NO_PUBKEY 89ABCDEF01234567\n"}
+	parseGPGVerification(missing)
+	assert.Equal(t, SignatureCannotCheck, missing.Status)
+	assert.Equal(t, "89ABCDEF01234567", missing.KeyID)
+	assert.Empty(t, missing.Signer)
+}
+
+func TestSignatureFormat(t *testing.T) {
+	assert.Equal(t, FormatGPG, signatureFormat("-----BEGIN PGP SIGNATURE-----\n"))
+	assert.Equal(t, FormatX509, signatureFormat("-----BEGIN SIGNED MESSAGE-----\n"))
+	assert.Equal(t, FormatSSH, signatureFormat("-----BEGIN SSH SIGNATURE-----\n"))
+	assert.Equal(t, FormatUnsigned, signatureFormat("tree abc\n"))
+}
+
+func TestCommitSignature(t *testing.T) {
+	var tests = []struct {
+		name     string
+		commit   string
+		expected SignatureFormat
+	}{
+		{
+			name: "signed",
+			commit: "tree abc\nauthor A <a@example.com> 1 +0000\n" +
+				"gpgsig -----BEGIN SSH SIGNATURE-----\n U1NIU0lH\n -----END SSH SIGNATURE-----\n\nmessage\n",
+			expected: FormatSSH,
+		},
+		{
+			name: "sha256 header",
+			commit: "tree abc\n" +
+				"gpgsig-sha256 -----BEGIN PGP SIGNATURE-----\n iQ\n -----END PGP SIGNATURE-----\n\nmessage\n",
+			expected: FormatGPG,
+		},
+		{
+			name: "unsigned merge of a signed tag",
+			commit: "tree abc\nparent a\nparent b\n" +
+				"mergetag object b\n type commit\n tag v1\n \n release\n -----BEGIN PGP SIGNATURE-----\n iQ\n -----END PGP SIGNATURE-----\n" +
+				"\nMerge tag 'v1'\n",
+			expected: FormatUnsigned,
+		},
+		{
+			name: "signed merge of a signed tag",
+			commit: "tree abc\nparent a\nparent b\n" +
+				"mergetag object b\n type commit\n tag v1\n \n release\n -----BEGIN PGP SIGNATURE-----\n iQ\n -----END PGP SIGNATURE-----\n" +
+				"gpgsig -----BEGIN SSH SIGNATURE-----\n U1NIU0lH\n -----END SSH SIGNATURE-----\n" +
+				"\nMerge tag 'v1'\n",
+			expected: FormatSSH,
+		},
+		{
+			name:     "signature in the message",
+			commit:   "tree abc\n\nquote\n-----BEGIN PGP SIGNATURE-----\niQ\n-----END PGP SIGNATURE-----\n",
+			expected: FormatUnsigned,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, signatureFormat(commitSignature(tc.commit)))
+		})
+	}
+}
+
+func TestTagSignatureFormat(t *testing.T) {
+	var tests = []struct {
+		name     string
+		tag      string
+		expected SignatureFormat
+	}{
+		{
+			name:     "signed",
+			tag:      "object abc\ntype commit\ntag v1\n\nrelease\n-----BEGIN SSH SIGNATURE-----\nU1NIU0lH\n-----END SSH SIGNATURE-----\n",
+			expected: FormatSSH,
+		},
+		{
+			name:     "message quoting a signature",
+			tag:      "object abc\ntype commit\ntag v1\n\nas signed:\n-----BEGIN PGP SIGNATURE-----\niQ\n-----END PGP SIGNATURE-----\nis fine\n",
+			expected: FormatUnsigned,
+		},
+		{
+			name: "signed tag quoting another signature",
+			tag: "object abc\ntype commit\ntag v1\n\nquote:\n-----BEGIN PGP SIGNATURE-----\niQ\n-----END PGP SIGNATURE-----\n" +
+				"-----BEGIN SSH SIGNATURE-----\nU1NIU0lH\n-----END SSH SIGNATURE-----\n",
+			expected: FormatSSH,
+		},
+		{
+			name:     "unsigned",
+			tag:      "object abc\ntype commit\ntag v1\n\nrelease\n",
+			expected: FormatUnsigned,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tagSignatureFormat(tc.tag))
+		})
+	}
+}
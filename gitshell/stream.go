@@ -0,0 +1,217 @@
+package gitshell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// maxRecordSize bounds the memory used for a single record of a streamed output
+const maxRecordSize = 16 * 1024 * 1024
+
+// stream runs a git command and splits its standard output into records as git produces them,
+// instead of buffering the whole output in memory.
+type stream struct {
+	// command is the git subcommand, for error messages
+	command string
+	cmd     *exec.Cmd
+	stdout  io.ReadCloser
+	stderr  bytes.Buffer
+	scanner *bufio.Scanner
+	err     error
+	done    bool
+}
+
+func startStream(inPath string, split bufio.SplitFunc, args ...string) (*stream, error) {
+	s := &stream{
+		command: subcommand(args),
+		cmd:     exec.Command("git", append([]string{"-C", inPath}, args...)...),
+	}
+	s.cmd.Stderr = &s.stderr
+	var err error
+	if s.stdout, err = s.cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	if err = s.cmd.Start(); err != nil {
+		return nil, err
+	}
+	s.scanner = bufio.NewScanner(s.stdout)
+	s.scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+	s.scanner.Split(split)
+	return s, nil
+}
+
+// globalOptionsWithValue are the options of git taking their value as a separate argument
+var globalOptionsWithValue = map[string]bool{
+	"-c": true, "-C": true, "--git-dir": true, "--work-tree": true, "--namespace": true, "--config-env": true,
+}
+
+// subcommand returns the git subcommand in the arguments, skipping the global options before it
+func subcommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case globalOptionsWithValue[args[i]]:
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			return args[i]
+		}
+	}
+	return ""
+}
+
+// next returns the next record, false once the output is exhausted or the stream failed.
+func (s *stream) next() ([]byte, bool) {
+	if s.done {
+		return nil, false
+	}
+	if s.scanner.Scan() {
+		return s.scanner.Bytes(), true
+	}
+	s.finish(s.scanner.Err())
+	return nil, false
+}
+
+// finish waits for git to exit and records the first error, including its exit status.
+func (s *stream) finish(err error) {
+	s.done = true
+	_, _ = io.Copy(io.Discard, s.stdout)
+	waitErr := s.cmd.Wait()
+	if err == nil && waitErr != nil {
+		err = fmt.Errorf("%s failed: %w: %s", strings.TrimSpace("git "+s.command), waitErr, strings.TrimSpace(s.stderr.String()))
+	}
+	s.err = err
+}
+
+// close stops the stream early, killing git if it is still running.
+func (s *stream) close() error {
+	if !s.done {
+		_ = s.cmd.Process.Kill()
+		s.finish(nil)
+		// git being killed is expected here
+		s.err = nil
+	}
+	return s.err
+}
+
+// splitOn returns a bufio.SplitFunc splitting on the given separator
+func splitOn(separator byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.IndexByte(data, separator); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// LineIterator reads the output of a git command line by line.
+// Callers must either iterate until Next returns false or call Close.
+type LineIterator struct {
+	stream *stream
+	line   string
+}
+
+// GitLines runs git with the given arguments in inPath and returns an iterator over its output lines,
+// i.e. GitLines(repo, "diff", "HEAD~1") to go through a large patch without holding it in memory.
+func GitLines(inPath string, args ...string) (*LineIterator, error) {
+	s, err := startStream(inPath, bufio.ScanLines, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &LineIterator{stream: s}, nil
+}
+
+// Next advances to the next line, it returns false at the end of the output or on error.
+func (it *LineIterator) Next() bool {
+	line, ok := it.stream.next()
+	if ok {
+		it.line = string(line)
+	}
+	return ok
+}
+
+// Line returns the current line without its line ending
+func (it *LineIterator) Line() string {
+	return it.line
+}
+
+// Err returns the first error encountered. A non zero exit status of git is reported
+// as an error wrapping *exec.ExitError. It is only meaningful once Next returned false.
+func (it *LineIterator) Err() error {
+	return it.stream.err
+}
+
+// Close stops the iteration early, killing git if it is still running.
+func (it *LineIterator) Close() error {
+	return it.stream.close()
+}
+
+// FileDiff is a single file changed between two commits
+type FileDiff struct {
+	Path   string
+	Change GitChange
+}
+
+// FileDiffIterator reads the files changed between two commits one by one.
+// Callers must either iterate until Next returns false or call Close.
+type FileDiffIterator struct {
+	stream *stream
+	diff   FileDiff
+}
+
+// GitFileDiffIterator returns an iterator over the files added, modified or deleted between two commits.
+// Other kinds of changes (i.e. type changes) are skipped.
+func GitFileDiffIterator(inPath, previousCommit, currentCommit string) (*FileDiffIterator, error) {
+	s, err := startStream(inPath, splitOn(0), "diff", "--no-renames", "--name-status", "-z", previousCommit, currentCommit)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDiffIterator{stream: s}, nil
+}
+
+// Next advances to the next changed file, it returns false at the end of the diff or on error.
+func (it *FileDiffIterator) Next() bool {
+	for {
+		// With -z the status and the path are separate records
+		status, ok := it.stream.next()
+		if !ok {
+			return false
+		}
+		mod, modErr := fromString(string(status))
+		path, ok := it.stream.next()
+		if !ok {
+			if it.stream.err == nil {
+				it.stream.err = fmt.Errorf("missing path for change %q", status)
+			}
+			return false
+		}
+		if modErr == nil {
+			it.diff = FileDiff{Path: string(path), Change: mod}
+			return true
+		}
+	}
+}
+
+// FileDiff returns the current changed file
+func (it *FileDiffIterator) FileDiff() FileDiff {
+	return it.diff
+}
+
+// Err returns the first error encountered. A non zero exit status of git is reported
+// as an error wrapping *exec.ExitError. It is only meaningful once Next returned false.
+func (it *FileDiffIterator) Err() error {
+	return it.stream.err
+}
+
+// Close stops the iteration early, killing git if it is still running.
+func (it *FileDiffIterator) Close() error {
+	return it.stream.close()
+}
@@ -0,0 +1,104 @@
+package gitshell
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-ch/go-libs/gitshell/gitshelltest"
+)
+
+func TestGitLines(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{
+			gitshelltest.Commit{Files: map[string]string{"file": "a\n"}},
+			gitshelltest.Commit{Files: map[string]string{"file": "b\n"}},
+		},
+	})
+
+	it, err := GitLines(repo.Path, "diff", "HEAD~1", "HEAD")
+	require.NoError(t, err)
+	var lines []string
+	for it.Next() {
+		lines = append(lines, it.Line())
+	}
+	assert.NoError(t, it.Err())
+	assert.Contains(t, lines, "-a")
+	assert.Contains(t, lines, "+b")
+}
+
+func TestGitLinesExitStatus(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{})
+
+	it, err := GitLines(repo.Path, "log")
+	require.NoError(t, err)
+	assert.False(t, it.Next(), "Expected no output from a log without commits")
+	var exitErr *exec.ExitError
+	assert.True(t, errors.As(it.Err(), &exitErr), "Expected the exit status to be surfaced, got: %v", it.Err())
+	assert.Equal(t, 128, exitErr.ExitCode())
+}
+
+func TestGitLinesCommandName(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{})
+
+	var tests = []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{name: "no arguments", args: nil, expectedErr: "git failed"},
+		{name: "global option", args: []string{"-c", "core.pager=cat", "log"}, expectedErr: "git log failed"},
+		{name: "joined global option", args: []string{"--git-dir=.git", "log"}, expectedErr: "git log failed"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			it, err := GitLines(repo.Path, tc.args...)
+			require.NoError(t, err)
+			for it.Next() {
+			}
+			assert.ErrorContains(t, it.Err(), tc.expectedErr)
+		})
+	}
+}
+
+func TestGitLinesClose(t *testing.T) {
+	// Large enough for git to block on the pipe before writing everything
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{gitshelltest.Commit{Files: map[string]string{"large": strings.Repeat("line\n", 200000)}}},
+	})
+
+	it, err := GitLines(repo.Path, "show", "HEAD")
+	require.NoError(t, err)
+	assert.True(t, it.Next())
+	assert.NoError(t, it.Close(), "Expected killing git on early termination not to be an error")
+	assert.False(t, it.Next())
+}
+
+func TestGitFileDiffIterator(t *testing.T) {
+	repo := gitshelltest.NewRepo(t, gitshelltest.Spec{
+		Steps: []gitshelltest.Step{
+			gitshelltest.Commit{Files: map[string]string{"old": "old"}},
+			gitshelltest.Commit{Files: map[string]string{"with space/änd ümlaut": "new"}, Delete: []string{"old"}},
+		},
+	})
+
+	it, err := GitFileDiffIterator(repo.Path, "HEAD~1", "HEAD")
+	require.NoError(t, err)
+	var diffs []FileDiff
+	for it.Next() {
+		diffs = append(diffs, it.FileDiff())
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []FileDiff{
+		{Path: "old", Change: Deleted},
+		{Path: "with space/änd ümlaut", Change: Added},
+	}, diffs)
+
+	changes, err := GitFileDiff(repo.Path, "HEAD", "does-not-exist")
+	assert.Error(t, err)
+	assert.Nil(t, changes, "Expected no changes when git fails")
+}
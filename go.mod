@@ -3,6 +3,7 @@ module github.com/open-ch/go-libs
 go 1.18
 
 require (
+	github.com/bazelbuild/buildtools v0.0.0-20240606140350-80f1f6802857
 	github.com/russross/blackfriday/v2 v2.1.0
 	github.com/stretchr/testify v1.8.4
 )